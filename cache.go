@@ -2,12 +2,31 @@ package cache
 
 import (
 	"net/http"
+	"time"
 )
 
 type Response struct {
 	StatusCode int         `msgpack:"status_code"`
 	Headers    http.Header `msgpack:"headers,omitempty"`
 	Body       []byte      `msgpack:"body,omitempty"`
+	// Unix milliseconds when the response was generated
+	CreatedAt int64 `msgpack:"created_at,omitempty"`
+	// Unix milliseconds when the response goes stale, zero means never
+	ExpiresAt int64 `msgpack:"expires_at,omitempty"`
+	// Unix milliseconds until the stale response can be served while revalidating
+	StaleUntil int64 `msgpack:"stale_until,omitempty"`
+	// Unix milliseconds until the stale response can be served when the handler fails
+	StaleIfErrorUntil int64 `msgpack:"stale_if_error_until,omitempty"`
+	// Request headers named by the `Vary` response header, only set on
+	// the variant index which points to the variants of a cache key
+	Vary []string `msgpack:"vary,omitempty"`
+	// Unix milliseconds when the variant index leaves the store, zero means never
+	StoredUntil int64 `msgpack:"stored_until,omitempty"`
+	// Store key of the body saved in chunks by a `store.StreamStore`,
+	// `Body` is empty when set
+	BodyKey string `msgpack:"body_key,omitempty"`
+	// Content coding the cache compressed `Body` with, empty when stored as is
+	Encoding string `msgpack:"encoding,omitempty"`
 }
 
 func NewResponse(code int, header http.Header, body []byte) *Response {
@@ -15,5 +34,11 @@ func NewResponse(code int, header http.Header, body []byte) *Response {
 		StatusCode: code,
 		Headers:    header,
 		Body:       body,
+		CreatedAt:  time.Now().UnixMilli(),
 	}
 }
+
+// Age returns how long ago the response was generated
+func (r *Response) Age(now time.Time) time.Duration {
+	return now.Sub(time.UnixMilli(r.CreatedAt))
+}
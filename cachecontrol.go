@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Delta-seconds greater than this value are treated as this value,
+// see https://www.rfc-editor.org/rfc/rfc9111#section-1.2.2
+const maxDeltaSeconds = 1<<31 - 1
+
+// cacheControl holds the directives of `Cache-Control` headers,
+// directive names are lowercased and quoted values are unquoted.
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, line := range header.Values(echo.HeaderCacheControl) {
+		for _, directive := range strings.Split(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			cc[name] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// seconds returns the delta-seconds value of directive `name`,
+// ok is false when the directive is missing or malformed.
+func (cc cacheControl) seconds(name string) (d time.Duration, ok bool) {
+	v, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(min(n, maxDeltaSeconds)) * time.Second, true
+}
+
+// expiresTTL returns the lifetime given by the `Expires` header relative to
+// the `Date` header, or to `now` when the response has no valid `Date`.
+// An invalid `Expires` value means the response is already expired.
+func expiresTTL(header http.Header, now time.Time) (time.Duration, bool) {
+	v := header.Get("Expires")
+	if v == "" {
+		return 0, false
+	}
+	expires, err := http.ParseTime(v)
+	if err != nil {
+		return 0, true
+	}
+	if date, err := http.ParseTime(header.Get("Date")); err == nil {
+		now = date
+	}
+	return expires.Sub(now), true
+}
+
+// responseTTL computes how long a response may be stored according to its
+// `Cache-Control` and `Expires` headers. `fallback` is used when the headers
+// don't specify a lifetime and caps the lifetime otherwise (zero means no cap).
+// ok is false when the response must not be stored.
+func responseTTL(header http.Header, fallback time.Duration, now time.Time) (ttl time.Duration, ok bool) {
+	cc := parseCacheControl(header)
+	// `no-cache` requires revalidation before every reuse which
+	// the middleware cannot do, so treat it like `no-store`
+	if cc.has("no-store") || cc.has("no-cache") || cc.has("private") {
+		return 0, false
+	}
+
+	ttl, ok = cc.seconds("s-maxage")
+	if !ok {
+		ttl, ok = cc.seconds("max-age")
+	}
+	if !ok {
+		ttl, ok = expiresTTL(header, now)
+	}
+	if !ok {
+		return fallback, true
+	}
+
+	if ttl <= 0 {
+		return 0, false
+	}
+	if fallback > 0 && ttl > fallback {
+		ttl = fallback
+	}
+	return ttl, true
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseTTL(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		fallback time.Duration
+		ttl      time.Duration
+		ok       bool
+	}{
+		{"no headers", http.Header{}, time.Minute, time.Minute, true},
+		{"max-age", http.Header{"Cache-Control": {"public, max-age=30"}}, 0, 30 * time.Second, true},
+		{"s-maxage over max-age", http.Header{"Cache-Control": {"max-age=30, s-maxage=10"}}, 0, 10 * time.Second, true},
+		{"capped by fallback", http.Header{"Cache-Control": {"max-age=3600"}}, time.Minute, time.Minute, true},
+		{"max-age=0", http.Header{"Cache-Control": {"max-age=0"}}, time.Minute, 0, false},
+		{"no-store", http.Header{"Cache-Control": {"no-store"}}, time.Minute, 0, false},
+		{"private", http.Header{"Cache-Control": {`private="Set-Cookie", max-age=60`}}, time.Minute, 0, false},
+		{"malformed max-age", http.Header{"Cache-Control": {"max-age=abc"}}, time.Minute, time.Minute, true},
+		{
+			"expires",
+			http.Header{"Expires": {now.Add(2 * time.Minute).Format(http.TimeFormat)}},
+			0, 2 * time.Minute, true,
+		},
+		{
+			"expires relative to date",
+			http.Header{
+				"Date":    {now.Add(-time.Minute).Format(http.TimeFormat)},
+				"Expires": {now.Add(time.Minute).Format(http.TimeFormat)},
+			},
+			0, 2 * time.Minute, true,
+		},
+		{"invalid expires", http.Header{"Expires": {"0"}}, time.Minute, 0, false},
+		{
+			"max-age over expires",
+			http.Header{
+				"Cache-Control": {"max-age=10"},
+				"Expires":       {now.Add(time.Hour).Format(http.TimeFormat)},
+			},
+			0, 10 * time.Second, true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ttl, ok := responseTTL(tt.header, tt.fallback, now)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.ttl, ttl)
+		})
+	}
+}
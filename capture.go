@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sdvcrx/echo-cache/store"
+)
+
+var (
+	errBodyDiscarded = errors.New("echo-cache: response body discarded")
+	errStoreBehind   = errors.New("echo-cache: store too slow to stream the response body")
+)
+
+// bodyStream writes a response body to a StreamStore in background. The store
+// runs with ctx, the body is discarded when the store falls `limit` bytes
+// behind so a slow store never slows down the response.
+type bodyStream struct {
+	key     string
+	buf     *streamBuffer
+	timeout time.Duration
+	cancel  context.CancelFunc
+	done    chan error
+}
+
+func startBodyStream(ctx context.Context, s store.StreamStore, key string, ttl time.Duration, limit int64, timeout time.Duration) *bodyStream {
+	ctx, cancel := context.WithCancel(ctx)
+	buf := newStreamBuffer(limit)
+	bs := &bodyStream{key: key, buf: buf, timeout: timeout, cancel: cancel, done: make(chan error, 1)}
+	stop := context.AfterFunc(ctx, func() {
+		buf.closeWithError(ctx.Err())
+	})
+	go func() {
+		err := store.SetStreamContext(ctx, s, key, buf, ttl)
+		stop()
+		cancel()
+		// fail the writer when the store gives up early
+		buf.closeWithError(err)
+		bs.done <- err
+	}()
+	return bs
+}
+
+func (bs *bodyStream) Write(b []byte) (int, error) {
+	return bs.buf.Write(b)
+}
+
+// close ends the body and waits until the store saved it,
+// at most `timeout` when set
+func (bs *bodyStream) close() error {
+	bs.buf.closeWithError(nil)
+	if bs.timeout > 0 {
+		timer := time.AfterFunc(bs.timeout, bs.cancel)
+		defer timer.Stop()
+	}
+	return <-bs.done
+}
+
+// abort makes the store discard the body
+func (bs *bodyStream) abort() {
+	bs.buf.closeWithError(errBodyDiscarded)
+	bs.cancel()
+	<-bs.done
+}
+
+// streamBuffer is a pipe whose writes never block,
+// they fail once limit bytes wait to be read
+type streamBuffer struct {
+	mu    sync.Mutex
+	ready *sync.Cond
+	buf   bytes.Buffer
+	limit int64
+	// err is returned once the buffer is read, io.EOF after close
+	err error
+}
+
+func newStreamBuffer(limit int64) *streamBuffer {
+	sb := &streamBuffer{limit: limit}
+	sb.ready = sync.NewCond(&sb.mu)
+	return sb
+}
+
+func (sb *streamBuffer) Write(p []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if sb.err != nil {
+		return 0, io.ErrClosedPipe
+	}
+	if int64(sb.buf.Len()) >= sb.limit {
+		return 0, errStoreBehind
+	}
+	sb.buf.Write(p)
+	sb.ready.Signal()
+	return len(p), nil
+}
+
+func (sb *streamBuffer) Read(p []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	for sb.buf.Len() == 0 && sb.err == nil {
+		sb.ready.Wait()
+	}
+	// a failed body is not read to the end
+	if sb.err != nil && sb.err != io.EOF {
+		return 0, sb.err
+	}
+	if sb.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return sb.buf.Read(p)
+}
+
+// closeWithError ends the buffer with err, io.EOF when nil.
+// The first failure is kept, it replaces the end of a closed buffer.
+func (sb *streamBuffer) closeWithError(err error) {
+	if err == nil {
+		err = io.EOF
+	}
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if sb.err == nil || sb.err == io.EOF {
+		sb.err = err
+	}
+	sb.ready.Broadcast()
+}
+
+// bodyCapture keeps a copy of the response body to save it. Bodies over
+// `threshold` are sent to the stream store in chunks instead of memory,
+// capturing stops as soon as the body exceeds `limit`.
+type bodyCapture struct {
+	buf       bytes.Buffer
+	size      int64
+	limit     int64
+	threshold int64
+	// overflow is set once the body is not captured anymore
+	overflow bool
+	// hash of the body for the generated `ETag`, nil when disabled
+	hash hash.Hash
+	// openStream starts streaming the body, nil disables streaming.
+	// It returns nil when the response is not going to be saved.
+	openStream func() *bodyStream
+	stream     *bodyStream
+}
+
+func newBodyCapture(limit int64, threshold int64, generateETag bool) *bodyCapture {
+	b := &bodyCapture{limit: limit, threshold: threshold}
+	if generateETag {
+		b.hash = sha256.New()
+	}
+	return b
+}
+
+// Write never fails so the response keeps going to the client
+func (b *bodyCapture) Write(p []byte) (int, error) {
+	n := len(p)
+	if b.overflow {
+		return n, nil
+	}
+	b.size += int64(n)
+	if b.limit > 0 && b.size > b.limit {
+		b.discard()
+		return n, nil
+	}
+	if b.hash != nil {
+		b.hash.Write(p)
+	}
+
+	if b.stream == nil && b.openStream != nil && int64(b.buf.Len()+n) > b.threshold {
+		if b.stream = b.openStream(); b.stream == nil {
+			b.discard()
+			return n, nil
+		}
+		if _, err := b.stream.Write(b.buf.Bytes()); err != nil {
+			b.discard()
+			return n, nil
+		}
+		b.buf = bytes.Buffer{}
+	}
+	if b.stream != nil {
+		if _, err := b.stream.Write(p); err != nil {
+			b.discard()
+		}
+		return n, nil
+	}
+
+	b.buf.Write(p)
+	return n, nil
+}
+
+// discard drops the captured body, the response won't be saved
+func (b *bodyCapture) discard() {
+	b.overflow = true
+	b.buf = bytes.Buffer{}
+	b.hash = nil
+	if b.stream != nil {
+		b.stream.abort()
+		b.stream = nil
+	}
+}
+
+// finish waits until the streamed body is saved, it returns
+// the store key of the body, which is empty when not streamed
+func (b *bodyCapture) finish() (string, error) {
+	bs := b.stream
+	if bs == nil {
+		return "", nil
+	}
+	b.stream = nil
+	return bs.key, bs.close()
+}
+
+// etag returns the strong `ETag` of the whole body,
+// it matches generateETag of the same body
+func (b *bodyCapture) etag() string {
+	if b.hash == nil {
+		return generateETag(b.buf.Bytes())
+	}
+	return `"` + hex.EncodeToString(b.hash.Sum(nil)[:16]) + `"`
+}
+
+// newBodyKey returns a unique store key of a streamed body,
+// so a body is never replaced while being read
+func newBodyKey(key string) string {
+	var id [8]byte
+	rand.Read(id[:])
+	return key + "-body-" + hex.EncodeToString(id[:])
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// flight is a handler call in progress for a cache key
+type flight struct {
+	done chan struct{}
+	// The saved response and its store key,
+	// resp is nil when the leader's response was not cacheable
+	resp     *Response
+	storeKey string
+}
+
+// flightGroup collapses concurrent cache misses of the same key
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{
+		flights: make(map[string]*flight),
+	}
+}
+
+// join returns the flight of key, leader is true when the caller
+// started it and must call `land` once the response is saved
+func (g *flightGroup) join(key string) (f *flight, leader bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if f, ok := g.flights[key]; ok {
+		return f, false
+	}
+	f = &flight{done: make(chan struct{})}
+	g.flights[key] = f
+	return f, true
+}
+
+// get returns the flight of key, or nil when no handler call is in progress
+func (g *flightGroup) get(key string) *flight {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.flights[key]
+}
+
+// land publishes the saved response to the waiters of the flight
+func (g *flightGroup) land(key string, f *flight, resp *Response, storeKey string) {
+	g.mu.Lock()
+	delete(g.flights, key)
+	g.mu.Unlock()
+
+	f.resp = resp
+	f.storeKey = storeKey
+	close(f.done)
+}
+
+// wait blocks until the leader lands, timeout (zero means no timeout)
+// expires or ctx is done. It returns nil when no response is available.
+func (f *flight) wait(ctx context.Context, timeout time.Duration) *Response {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	select {
+	case <-f.done:
+		return f.resp
+	case <-ctx.Done():
+		return nil
+	}
+}
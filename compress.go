@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/labstack/echo/v4"
+)
+
+// Content codings the cached bodies can be compressed with
+const (
+	EncodingGzip   = "gzip"
+	EncodingBrotli = "br"
+	EncodingZstd   = "zstd"
+)
+
+// EncodeAll and DecodeAll are safe for concurrent use
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+func validEncoding(encoding string) bool {
+	switch encoding {
+	case EncodingGzip, EncodingBrotli, EncodingZstd:
+		return true
+	}
+	return false
+}
+
+func compressBody(encoding string, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case EncodingGzip:
+		w = gzip.NewWriter(&buf)
+	case EncodingBrotli:
+		w = brotli.NewWriter(&buf)
+	case EncodingZstd:
+		return zstdEncoder.EncodeAll(body, nil), nil
+	default:
+		return nil, fmt.Errorf("echo-cache: unsupported encoding %q", encoding)
+	}
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompressBody(encoding string, body []byte) ([]byte, error) {
+	var r io.Reader
+	switch encoding {
+	case EncodingGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	case EncodingBrotli:
+		r = brotli.NewReader(bytes.NewReader(body))
+	case EncodingZstd:
+		return zstdDecoder.DecodeAll(body, nil)
+	default:
+		return nil, fmt.Errorf("echo-cache: unsupported encoding %q", encoding)
+	}
+	return io.ReadAll(r)
+}
+
+// acceptsEncoding reports whether the `Accept-Encoding` header allows encoding
+func acceptsEncoding(accept string, encoding string) bool {
+	wildcard := false
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(k, "q") {
+				// invalid weights are not acceptable
+				q, _ = strconv.ParseFloat(v, 64)
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case encoding:
+			return q > 0
+		case "*":
+			wildcard = q > 0
+		}
+	}
+	return wildcard
+}
+
+// encodedETag derives the entity tag of the compressed representation,
+// e.g. `"abc"` becomes `"abc-gzip"`
+func encodedETag(etag string, encoding string) string {
+	if strings.HasSuffix(etag, `"`) {
+		return etag[:len(etag)-1] + "-" + encoding + `"`
+	}
+	return etag + "-" + encoding
+}
+
+// compress replaces the body of resp with its compressed form
+// when it is smaller, bodies already encoded are left alone
+func compress(resp *Response, encoding string) error {
+	if len(resp.Body) == 0 || resp.Headers.Get(echo.HeaderContentEncoding) != "" {
+		return nil
+	}
+	body, err := compressBody(encoding, resp.Body)
+	if err != nil || len(body) >= len(resp.Body) {
+		return err
+	}
+	resp.Body = body
+	resp.Encoding = encoding
+	return nil
+}
+
+// represent returns the representation of resp sent to the client of c:
+// the compressed body when `Accept-Encoding` allows it,
+// the decompressed body otherwise. Both vary on `Accept-Encoding`.
+func represent(c echo.Context, resp *Response) (*Response, error) {
+	if resp.Encoding == "" {
+		return resp, nil
+	}
+
+	rep := *resp
+	rep.Encoding = ""
+	rep.Headers = resp.Headers.Clone()
+	if rep.Headers == nil {
+		rep.Headers = http.Header{}
+	}
+	if !slices.Contains(parseVary(rep.Headers), echo.HeaderAcceptEncoding) {
+		rep.Headers.Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+	}
+	if acceptsEncoding(c.Request().Header.Get(echo.HeaderAcceptEncoding), resp.Encoding) {
+		rep.Headers.Set(echo.HeaderContentEncoding, resp.Encoding)
+		if etag := rep.Headers.Get("ETag"); etag != "" {
+			rep.Headers.Set("ETag", encodedETag(etag, resp.Encoding))
+		}
+	} else {
+		body, err := decompressBody(resp.Encoding, resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		rep.Body = body
+	}
+	if rep.Headers.Get(echo.HeaderContentLength) != "" {
+		rep.Headers.Set(echo.HeaderContentLength, strconv.Itoa(len(rep.Body)))
+	}
+	return &rep, nil
+}
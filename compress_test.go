@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		accept   string
+		encoding string
+		ok       bool
+	}{
+		{"", "gzip", false},
+		{"gzip, deflate, br", "br", true},
+		{"gzip, deflate", "zstd", false},
+		{"GZIP", "gzip", true},
+		{"gzip;q=0", "gzip", false},
+		{"br;q=0.5, gzip; q=1.0", "gzip", true},
+		{"*", "zstd", true},
+		{"gzip;q=0, *", "gzip", false},
+		{"*;q=0", "br", false},
+		{"gzip;q=abc", "gzip", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.ok, acceptsEncoding(tt.accept, tt.encoding), "%q accepts %s", tt.accept, tt.encoding)
+	}
+}
+
+func TestEncodedETag(t *testing.T) {
+	assert.Equal(t, `"abc-gzip"`, encodedETag(`"abc"`, EncodingGzip))
+	assert.Equal(t, `W/"abc-br"`, encodedETag(`W/"abc"`, EncodingBrotli))
+}
+
+func TestCompressBody(t *testing.T) {
+	body := []byte(strings.Repeat("hello ", 100))
+	for _, encoding := range []string{EncodingGzip, EncodingBrotli, EncodingZstd} {
+		compressed, err := compressBody(encoding, body)
+		assert.NoError(t, err)
+		assert.Less(t, len(compressed), len(body))
+
+		b, err := decompressBody(encoding, compressed)
+		assert.NoError(t, err)
+		assert.Equal(t, body, b)
+	}
+
+	_, err := compressBody("deflate", body)
+	assert.Error(t, err)
+}
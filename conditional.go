@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Headers sent with a 304 response,
+// see https://www.rfc-editor.org/rfc/rfc9110#section-15.4.5
+var notModifiedHeaders = []string{
+	echo.HeaderCacheControl,
+	"Content-Location",
+	"Date",
+	"ETag",
+	"Expires",
+	echo.HeaderLastModified,
+	echo.HeaderVary,
+}
+
+// generateETag returns a strong ETag derived from the body
+func generateETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatch reports whether `etag` matches one of the entity tags
+// of an `If-None-Match` header using the weak comparison
+func etagMatch(ifNoneMatch string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified reports whether the conditional request can be answered
+// with 304 according to the validators of the cached response.
+// `If-Modified-Since` is ignored when `If-None-Match` is present.
+func notModified(req *http.Request, resp *Response) bool {
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+
+	if ifNoneMatch := req.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		etag := resp.Headers.Get("ETag")
+		return etag != "" && etagMatch(ifNoneMatch, etag)
+	}
+
+	since, err := http.ParseTime(req.Header.Get(echo.HeaderIfModifiedSince))
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(resp.Headers.Get(echo.HeaderLastModified))
+	if err != nil {
+		return false
+	}
+	return !lastModified.After(since)
+}
+
+func writeNotModified(c echo.Context, resp *Response) {
+	header := c.Response().Header()
+	for _, name := range notModifiedHeaders {
+		if values := resp.Headers.Values(name); len(values) > 0 {
+			header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	c.Response().WriteHeader(http.StatusNotModified)
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Diagnostic response headers, they are not saved with the cached response.
+const (
+	HeaderXCache      = "X-Cache"
+	HeaderCacheStatus = "Cache-Status"
+)
+
+// Values of the `X-Cache` header
+const (
+	CacheHit    = "HIT"
+	CacheMiss   = "MISS"
+	CacheBypass = "BYPASS"
+	CacheStale  = "STALE"
+)
+
+// Name of the cache in the `Cache-Status` header
+const cacheStatusName = "echo-cache"
+
+// cacheStatus records how the cache handled a request
+type cacheStatus struct {
+	result string
+	key    string
+	// The cached response served, nil on miss
+	resp *Response
+	// Why the request went to the handler, see
+	// https://www.rfc-editor.org/rfc/rfc9211#section-2.2
+	fwd       string
+	fwdStatus int
+	collapsed bool
+}
+
+// hit records that resp is served from the cache
+func (s *cacheStatus) hit(resp *Response, now time.Time) {
+	s.resp = resp
+	s.fwd = ""
+	s.result = CacheHit
+	if resp.stale(now) {
+		s.result = CacheStale
+	}
+}
+
+// diagnose sends the diagnostic headers of status with the response of c,
+// status can still change until the response is written.
+func (m *cacheMiddleware) diagnose(c echo.Context, status *cacheStatus) {
+	config := &m.config
+	if !config.DiagnosticHeaders ||
+		(config.DiagnosticsSkipper != nil && config.DiagnosticsSkipper(c)) {
+		return
+	}
+	c.Response().Before(func() {
+		status.writeHeaders(c.Response().Header(), time.Now())
+	})
+}
+
+func (s *cacheStatus) writeHeaders(header http.Header, now time.Time) {
+	header.Set(HeaderXCache, s.result)
+
+	var b strings.Builder
+	b.WriteString(cacheStatusName)
+	if s.fwd == "" {
+		b.WriteString("; hit")
+	} else {
+		b.WriteString("; fwd=" + s.fwd)
+		if s.fwdStatus != 0 {
+			b.WriteString("; fwd-status=" + strconv.Itoa(s.fwdStatus))
+		}
+		if s.collapsed {
+			b.WriteString("; collapsed")
+		}
+	}
+	if s.resp != nil {
+		header.Set("Age", strconv.FormatInt(int64(max(s.resp.Age(now), 0)/time.Second), 10))
+		if s.resp.ExpiresAt > 0 {
+			// negative once stale
+			ttl := (s.resp.ExpiresAt - now.UnixMilli()) / 1000
+			b.WriteString("; ttl=" + strconv.FormatInt(ttl, 10))
+		}
+	}
+	if s.key != "" {
+		b.WriteString("; key=" + sfString(s.key))
+	}
+	header.Set(HeaderCacheStatus, b.String())
+}
+
+// sfString formats s as a structured field string,
+// characters which can't be represented are dropped
+func sfString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == '"' || ch == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(ch)
+		case ch >= 0x20 && ch < 0x7f:
+			b.WriteByte(ch)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
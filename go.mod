@@ -4,11 +4,16 @@ replace github.com/sdvcrx/echo-cache/store => ./store
 
 replace github.com/sdvcrx/echo-cache/store/memory => ./store/memory
 
+replace github.com/sdvcrx/echo-cache/store/compress => ./store/compress
+
 go 1.23
 
 require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/klauspost/compress v1.18.0
 	github.com/labstack/echo/v4 v4.13.3
 	github.com/sdvcrx/echo-cache/store v0.3.0
+	github.com/sdvcrx/echo-cache/store/compress v0.3.0
 	github.com/sdvcrx/echo-cache/store/memory v0.3.0
 	github.com/stretchr/testify v1.10.0
 	github.com/vmihailenco/msgpack/v5 v5.4.1
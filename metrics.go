@@ -5,7 +5,9 @@ type Metrics interface {
 	CacheHits()
 	// The total number of cache misses
 	CacheMisses()
-	// The current size of the cache in bytes
+	// The current size of the cache in bytes, read from stores implementing
+	// `store.PrefixSizer` or `store.Inspector` when `StoreSizeInterval` is set
+	// and the size of each saved response otherwise
 	CacheSize(size float64)
 	// The time it takes for the middleware to retrieve data from the cache
 	CacheLatency(latency float64)
@@ -3,11 +3,17 @@ package cache
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"maps"
 	"net"
 	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -27,24 +33,91 @@ type CacheConfig struct {
 	Store            store.Store
 	Encoder          Encoder
 	Metrics          Metrics
+
+	// Use the `Cache-Control` and `Expires` headers of the response to decide
+	// whether and how long it is cached, `CacheDuration` acts as the fallback
+	// and the upper bound of the lifetime.
+	RespectResponseCacheControl bool
+	// Honor the `Cache-Control` directives of the request:
+	// `no-cache` skips the cache lookup, `no-store` skips saving the response,
+	// `max-age` rejects older entries and `only-if-cached` responds 504 on miss.
+	RespectRequestCacheControl bool
+	// Generate a strong `ETag` from the body when saving a response without one,
+	// the generated `ETag` is only sent with cached responses.
+	GenerateETag bool
+	// Collapse concurrent cache misses of the same key so only one request runs
+	// the handler and the others are served its response. Waiting requests run
+	// the handler themselves after `CoalesceTimeout` (zero means no timeout)
+	// or when the response can't be cached.
+	CoalesceRequests bool
+	CoalesceTimeout  time.Duration
+	// Serve stale responses for this long after they expire while refreshing
+	// them in background, overridden by the `stale-while-revalidate` directive
+	// when `RespectResponseCacheControl` is set.
+	StaleWhileRevalidate time.Duration
+	// Keep responses for this long after they expire and serve them when the
+	// handler fails with an error or a 5xx status, overridden by the
+	// `stale-if-error` directive when `RespectResponseCacheControl` is set.
+	StaleIfError time.Duration
+	// Cancel the request context of the handler after this long when a stale
+	// response can be served, the handler failing with the context error gets
+	// the stale response served. A handler ignoring its context isn't
+	// interrupted. Zero, the default, means no timeout.
+	StaleIfErrorTimeout time.Duration
+	// Timeout of each store operation, which is also bounded by the request context.
+	// Stores implementing `store.ContextStore`, `store.ContextTagStore` or
+	// `store.ContextStreamStore` are called with the context. A streamed body
+	// is saved while it is sent, the timeout starts once it ends, and reading
+	// one is bounded by the timeout until it is opened.
+	StoreTimeout time.Duration
+	// Stop capturing the response body once it exceeds this size, the response
+	// is still sent to the client but not cached. Defaults to `DefaultMaxCacheableSize`,
+	// a negative value disables the limit. Note `DefaultCanCacheResponseSkipper`
+	// also refuses responses larger than 10MB.
+	MaxCacheableSize int64
+	// Bodies larger than this are written in chunks to stores implementing
+	// `store.StreamStore` instead of being held in memory,
+	// defaults to `DefaultStreamThreshold`. The body isn't cached when the
+	// store falls this far behind the response, so it never slows it down.
+	StreamThreshold int64
+	// Read the size of stores implementing `store.PrefixSizer` or `store.Inspector`
+	// for `Metrics.CacheSize` at most this often, in background after a save.
+	// Reading it may scan the whole store. Zero, the default, reports the size
+	// of each saved response instead.
+	StoreSizeInterval time.Duration
+	// Lifetime of the streamed bodies of responses cached without expiry, so
+	// bodies left behind by purged or replaced responses are removed eventually.
+	// A response whose body expired is fetched again. Defaults to `DefaultStreamBodyTTL`.
+	StreamBodyTTL time.Duration
+	// Send the `X-Cache` (HIT, MISS, BYPASS or STALE), `Age` and `Cache-Status`
+	// headers, `DiagnosticsSkipper` can restrict them to trusted clients.
+	DiagnosticHeaders  bool
+	DiagnosticsSkipper middleware.Skipper
+	// Store bodies compressed with this content coding (`EncodingGzip`,
+	// `EncodingBrotli` or `EncodingZstd`), they are sent as is to clients
+	// accepting it and decompressed for the others. Bodies already encoded
+	// by the handler and streamed bodies are not compressed.
+	Compression string
 }
 
+// DefaultCacheKey derives the key from the method and URL,
+// HEAD requests share the key of GET to be served from its entry
 func DefaultCacheKey(prefix string, req *http.Request) string {
-	return fmt.Sprintf("%s-%s-%s", prefix, req.Method, req.URL)
+	method := req.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	return fmt.Sprintf("%s-%s-%s", prefix, method, req.URL)
 }
 
-// Cache default skipper only cache GET/HEAD method
-// and headers not contain `Range`
+// Cache default skipper only cache GET/HEAD method,
+// `Range` requests are served from the cached full response
 func DefaultCacheSkipper(c echo.Context) bool {
 	method := c.Request().Method
 	// Request must use GET or HEAD method
 	if method != http.MethodGet && method != http.MethodHead {
 		return true
 	}
-	// Request must not contain the `Range` header
-	if c.Request().Header.Get("range") != "" {
-		return true
-	}
 	return false
 }
 
@@ -80,9 +153,12 @@ func DefaultCanCacheResponseSkipper(c echo.Context) bool {
 }
 
 var (
-	DefaultCachePrefix   = "cache"
-	DefaultCacheDuration = time.Duration(0)
-	DefaultCacheConfig   = CacheConfig{
+	DefaultCachePrefix      = "cache"
+	DefaultCacheDuration    = time.Duration(0)
+	DefaultMaxCacheableSize = 10 * SizeMB
+	DefaultStreamThreshold  = SizeMB
+	DefaultStreamBodyTTL    = 24 * time.Hour
+	DefaultCacheConfig      = CacheConfig{
 		Skipper:          DefaultCacheSkipper,
 		CanCacheResponse: DefaultCanCacheResponseSkipper,
 		CachePrefix:      DefaultCachePrefix,
@@ -103,7 +179,9 @@ func (w *bodyDumpResponseWriter) WriteHeader(code int) {
 }
 
 func (w *bodyDumpResponseWriter) Write(b []byte) (int, error) {
-	return w.Writer.Write(b)
+	n, err := w.ResponseWriter.Write(b)
+	w.Writer.Write(b[:n])
+	return n, err
 }
 
 func (w *bodyDumpResponseWriter) Flush() {
@@ -139,75 +217,601 @@ func CacheWithConfig(config CacheConfig) echo.MiddlewareFunc {
 	if config.Metrics == nil {
 		config.Metrics = &dummyMetrics{}
 	}
+	if config.MaxCacheableSize == 0 {
+		config.MaxCacheableSize = DefaultMaxCacheableSize
+	}
+	if config.StreamThreshold == 0 {
+		config.StreamThreshold = DefaultStreamThreshold
+	}
+	if config.StreamBodyTTL == 0 {
+		config.StreamBodyTTL = DefaultStreamBodyTTL
+	}
+	if config.Compression != "" && !validEncoding(config.Compression) {
+		panic("echo-cache: unsupported compression " + config.Compression)
+	}
+
+	m := &cacheMiddleware{
+		config:  config,
+		store:   store.WithContext(config.Store),
+		flights: newFlightGroup(),
+	}
+	m.streams, _ = config.Store.(store.StreamStore)
 
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			if config.Skipper(c) {
-				config.Metrics.CacheMisses()
-				return next(c)
-			}
+			return m.handle(c, next)
+		}
+	}
+}
 
-			// before response
-			start := time.Now()
-			req := c.Request()
-			key := config.CacheKey(config.CachePrefix, req)
-
-			cached, err := config.Store.Get(key)
-
-			if err != nil {
-				config.Metrics.CacheError()
-				c.Logger().Errorf("[echo-cache] Failed to get cache, err=%s", err)
-			} else if cached != nil {
-				var cachedResponse Response
-				if err := config.Encoder.Unmarshal(cached, &cachedResponse); err != nil {
-					config.Metrics.CacheError()
-					c.Logger().Errorf("[echo-cache] Failed to unmarshal response, err=%s", err)
-					return nil
-				}
+type cacheMiddleware struct {
+	config CacheConfig
+	store  store.ContextStore
+	// Set when the store can save bodies in chunks
+	streams store.StreamStore
+	flights *flightGroup
+	// Keys being refreshed in background
+	refreshing sync.Map
+	// Unix nanoseconds of the last size read from the store
+	sizeReportedAt atomic.Int64
+	// Set once the store failed to report its size as not supported
+	sizeUnsupported atomic.Bool
+}
 
-				maps.Copy(c.Response().Header(), cachedResponse.Headers)
-				c.Response().WriteHeader(cachedResponse.StatusCode)
-				_, err = c.Response().Write(cachedResponse.Body)
-				if err != nil {
-					c.Logger().Errorf("[echo-cache] Failed to write response, err=%s", err)
-				}
-				config.Metrics.CacheHits()
-				config.Metrics.CacheLatency(float64(time.Since(start).Seconds()))
-				return nil
-			}
+func (m *cacheMiddleware) handle(c echo.Context, next echo.HandlerFunc) error {
+	config := &m.config
+	if config.Skipper(c) {
+		config.Metrics.CacheMisses()
+		m.diagnose(c, &cacheStatus{result: CacheBypass, fwd: "bypass"})
+		return next(c)
+	}
 
-			config.Metrics.CacheMisses()
+	// before response
+	start := time.Now()
+	req := c.Request()
+	key := config.CacheKey(config.CachePrefix, req)
+	status := &cacheStatus{result: CacheMiss, key: key, fwd: "miss"}
+	m.diagnose(c, status)
 
-			// copy from https://github.com/labstack/echo/blob/master/middleware/body_dump.go
-			resBody := new(bytes.Buffer)
-			mw := io.MultiWriter(c.Response().Writer, resBody)
-			writer := &bodyDumpResponseWriter{Writer: mw, ResponseWriter: c.Response().Writer}
-			c.Response().Writer = writer
+	var reqCacheControl cacheControl
+	if config.RespectRequestCacheControl {
+		reqCacheControl = parseCacheControl(req.Header)
+	}
 
-			// start
-			if err := next(c); err != nil {
-				c.Error(err)
-			}
+	var index, cachedResponse *Response
+	if !reqCacheControl.has("no-cache") {
+		cachedResponse = m.load(c, key)
+	} else {
+		status.fwd = "request"
+	}
+	if cachedResponse.isVariantIndex() {
+		index = cachedResponse
+		cachedResponse = m.load(c, varyKey(key, index.Vary, req.Header))
+		// saved before the index was purged
+		if cachedResponse != nil && cachedResponse.CreatedAt < index.CreatedAt {
+			cachedResponse = nil
+		}
+		if cachedResponse == nil {
+			status.fwd = "vary-miss"
+		}
+	}
+
+	if maxAge, ok := reqCacheControl.seconds("max-age"); ok &&
+		cachedResponse != nil && cachedResponse.Age(start) > maxAge {
+		// too old for the client, treat as miss
+		cachedResponse = nil
+	}
 
-			// don't cache status code != 200
-			// TODO add canCache
-			// https://vercel.com/docs/concepts/functions/edge-functions/edge-caching#what-is-cached
-			if config.CanCacheResponse(c) {
-				return nil
+	// the stale response served when the handler fails
+	var fallback *Response
+	if cachedResponse != nil && cachedResponse.stale(start) {
+		if cachedResponse.revalidatable(start) {
+			// a HEAD response can't refresh the entry
+			if req.Method != http.MethodHead {
+				m.refresh(c, next, key, index)
 			}
-			// cache it here
-			resp := NewResponse(writer.statusCode, writer.Header(), resBody.Bytes())
-			b, err := config.Encoder.Marshal(resp)
-			if err != nil {
-				c.Logger().Errorf("[echo-cache] Failed to marshal response, err=%s", err)
-				return nil
+		} else {
+			if cachedResponse.usableIfError(start) {
+				fallback = cachedResponse
 			}
-			config.Metrics.CacheSize(float64(len(b)))
-			if err = config.Store.Set(key, b, config.CacheDuration); err != nil {
-				c.Logger().Errorf("[echo-cache] Failed to save cache, key=%s err=%s", key, err)
+			cachedResponse = nil
+			status.fwd = "stale"
+		}
+	}
+
+	if cachedResponse != nil {
+		status.hit(cachedResponse, start)
+		if m.serve(c, cachedResponse) {
+			config.Metrics.CacheHits()
+			config.Metrics.CacheLatency(float64(time.Since(start).Seconds()))
+			return nil
+		}
+		*status = cacheStatus{result: CacheMiss, key: key, fwd: "miss"}
+	}
+
+	config.Metrics.CacheMisses()
+
+	if reqCacheControl.has("only-if-cached") {
+		return echo.ErrGatewayTimeout
+	}
+
+	if fallback != nil {
+		m.serveStaleIfError(c, next, key, index, fallback, status)
+		return nil
+	}
+
+	// the response saved by this request
+	var saved *Response
+	var storeKey string
+
+	if config.CoalesceRequests && req.Method != http.MethodHead {
+		var f *flight
+		var leader bool
+		if req.Header.Get("Range") != "" {
+			// a partial response can't be shared, only wait for a full one
+			f = m.flights.get(key)
+		} else {
+			f, leader = m.flights.join(key)
+		}
+		if leader {
+			defer func() {
+				m.flights.land(key, f, saved, storeKey)
+			}()
+		} else if f != nil {
+			if resp := f.wait(req.Context(), config.CoalesceTimeout); resp != nil &&
+				varyKeyOf(key, resp, req.Header) == f.storeKey {
+				status.collapsed = true
+				if m.serve(c, resp) {
+					return nil
+				}
+				status.collapsed = false
 			}
+		}
+	}
+
+	// copy from https://github.com/labstack/echo/blob/master/middleware/body_dump.go
+	capture := m.newCapture(c, key)
+	defer capture.discard()
+	if reqCacheControl.has("no-store") || req.Method == http.MethodHead {
+		capture.discard()
+	}
+	writer := &bodyDumpResponseWriter{Writer: capture, ResponseWriter: c.Response().Writer}
+	c.Response().Writer = writer
+
+	// start
+	if err := next(c); err != nil {
+		c.Error(err)
+	}
+
+	saved, storeKey = m.save(c, key, index, capture)
+	return nil
+}
+
+// newCapture returns the capture of the response body of c,
+// the body is streamed when the store supports it
+func (m *cacheMiddleware) newCapture(c echo.Context, key string) *bodyCapture {
+	config := &m.config
+	capture := newBodyCapture(config.MaxCacheableSize, config.StreamThreshold, config.GenerateETag)
+	if m.streams == nil {
+		return capture
+	}
+	capture.openStream = func() *bodyStream {
+		// the headers are sent before the body, decide with them
+		if c.Response().Status == http.StatusPartialContent || config.CanCacheResponse(c) {
+			return nil
+		}
+		ttl, grace, errorGrace, ok := m.lifetime(c.Response().Header())
+		if !ok {
 			return nil
 		}
+		if ttl > 0 {
+			ttl += max(grace, errorGrace)
+		} else {
+			ttl = config.StreamBodyTTL
+		}
+		return startBodyStream(c.Request().Context(), m.streams, newBodyKey(key), ttl, 2*config.StreamThreshold, config.StoreTimeout)
+	}
+	return capture
+}
+
+// lifetime returns how long a response with header stays fresh and how long
+// it can be served stale after, ok is false when it must not be cached.
+func (m *cacheMiddleware) lifetime(header http.Header) (ttl, grace, errorGrace time.Duration, ok bool) {
+	config := &m.config
+	ttl = config.CacheDuration
+	grace = config.StaleWhileRevalidate
+	errorGrace = config.StaleIfError
+	if config.RespectResponseCacheControl {
+		ttl, ok = responseTTL(header, config.CacheDuration, time.Now())
+		if !ok {
+			return 0, 0, 0, false
+		}
+		cc := parseCacheControl(header)
+		if swr, ok := cc.seconds("stale-while-revalidate"); ok {
+			grace = swr
+		}
+		if sie, ok := cc.seconds("stale-if-error"); ok {
+			errorGrace = sie
+		}
+	}
+
+	if slices.Contains(parseVary(header), "*") {
+		return 0, 0, 0, false
+	}
+	return ttl, grace, errorGrace, true
+}
+
+// save caches the response of c with the captured body, it returns the
+// saved response and its store key, or nil when the response is not cacheable.
+func (m *cacheMiddleware) save(c echo.Context, key string, index *Response, capture *bodyCapture) (*Response, string) {
+	config := &m.config
+	defer capture.discard()
+
+	// body exceeded `MaxCacheableSize` or was discarded
+	if capture.overflow {
+		return nil, ""
+	}
+
+	// the body of a HEAD response is empty, it must not replace the GET entry
+	if c.Request().Method == http.MethodHead {
+		return nil, ""
+	}
+
+	// the handler answered a Range request with a part of the response
+	if c.Response().Status == http.StatusPartialContent {
+		return nil, ""
+	}
+
+	// don't cache status code != 200
+	// TODO add canCache
+	// https://vercel.com/docs/concepts/functions/edge-functions/edge-caching#what-is-cached
+	if config.CanCacheResponse(c) {
+		return nil, ""
+	}
+
+	header := c.Response().Header()
+	ttl, grace, errorGrace, ok := m.lifetime(header)
+	if !ok {
+		return nil, ""
+	}
+	vary := parseVary(header)
+
+	// cache it here
+	resp := NewResponse(c.Response().Status, header.Clone(), capture.buf.Bytes())
+	bodyKey, err := capture.finish()
+	if err != nil {
+		config.Metrics.CacheError()
+		c.Logger().Errorf("[echo-cache] Failed to save response body, key=%s err=%s", key, err)
+		return nil, ""
+	}
+	resp.BodyKey = bodyKey
+	tags := responseTags(c)
+	resp.Headers.Del(HeaderSurrogateKey)
+	if config.DiagnosticHeaders {
+		resp.Headers.Del(HeaderXCache)
+		resp.Headers.Del(HeaderCacheStatus)
+	}
+	if config.GenerateETag && resp.StatusCode == http.StatusOK && resp.Headers.Get("ETag") == "" {
+		resp.Headers.Set("ETag", capture.etag())
+	}
+	if config.Compression != "" && resp.BodyKey == "" {
+		if err := compress(resp, config.Compression); err != nil {
+			c.Logger().Errorf("[echo-cache] Failed to compress response, key=%s err=%s", key, err)
+		}
+	}
+
+	// zero ttl means the response never goes stale
+	storeTTL := ttl
+	if ttl > 0 {
+		resp.ExpiresAt = resp.CreatedAt + ttl.Milliseconds()
+		resp.StaleUntil = resp.ExpiresAt + grace.Milliseconds()
+		resp.StaleIfErrorUntil = resp.ExpiresAt + errorGrace.Milliseconds()
+		storeTTL = ttl + max(grace, errorGrace)
+	}
+
+	storeKey := key
+	if len(vary) > 0 {
+		m.saveVariantIndex(c, key, index, vary, resp.CreatedAt, storeTTL)
+		storeKey = varyKey(key, vary, c.Request().Header)
+	}
+
+	if !m.saveResponse(c, storeKey, resp, storeTTL) {
+		return nil, ""
+	}
+	if len(tags) > 0 {
+		m.tag(c, storeKey, tags, storeTTL)
+	}
+	return resp, storeKey
+}
+
+// saveVariantIndex saves the index of the variants of key before a variant
+// is saved with ttl. The existing index, loaded when the lookup was skipped,
+// is kept so the saved variants stay reachable, its ttl is extended to outlive
+// the new variant. It is replaced when the response varies on other headers.
+func (m *cacheMiddleware) saveVariantIndex(c echo.Context, key string, index *Response, vary []string, createdAt int64, ttl time.Duration) {
+	if index == nil {
+		if existing := m.load(c, key); existing.isVariantIndex() {
+			index = existing
+		}
+	}
+	var storedUntil int64
+	if ttl > 0 {
+		storedUntil = time.Now().Add(ttl).UnixMilli()
+	}
+
+	if index == nil || !slices.Equal(index.Vary, vary) {
+		index = newVariantIndex(vary, createdAt)
+	} else if index.StoredUntil == 0 || (storedUntil != 0 && storedUntil <= index.StoredUntil) {
+		// outlives the new variant
+		return
+	}
+	index.StoredUntil = storedUntil
+	m.saveResponse(c, key, index, ttl)
+}
+
+// tag associates key with tags when the store supports tags, errors are logged.
+// Wrapper stores report a wrapped store without tags with store.ErrNotSupported.
+func (m *cacheMiddleware) tag(c echo.Context, key string, tags []string, ttl time.Duration) {
+	ts, ok := m.config.Store.(store.TagStore)
+	var err error
+	if ok {
+		ctx, cancel := m.storeContext(c)
+		err = store.TagContext(ctx, ts, key, tags, ttl)
+		cancel()
+	}
+	if !ok || errors.Is(err, store.ErrNotSupported) {
+		c.Logger().Warnf("[echo-cache] Store doesn't support tags, key=%s", key)
+		return
+	}
+	if err != nil {
+		m.config.Metrics.CacheError()
+		c.Logger().Errorf("[echo-cache] Failed to tag cache, key=%s err=%s", key, err)
+	}
+}
+
+// storeContext bounds a store operation by the request and `StoreTimeout`
+func (m *cacheMiddleware) storeContext(c echo.Context) (context.Context, context.CancelFunc) {
+	ctx := c.Request().Context()
+	if m.config.StoreTimeout > 0 {
+		return context.WithTimeout(ctx, m.config.StoreTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// load reads the response saved under key,
+// errors are logged and reported as a miss.
+func (m *cacheMiddleware) load(c echo.Context, key string) *Response {
+	config := &m.config
+	ctx, cancel := m.storeContext(c)
+	defer cancel()
+
+	cached, err := m.store.GetContext(ctx, key)
+	if err != nil {
+		config.Metrics.CacheError()
+		c.Logger().Errorf("[echo-cache] Failed to get cache, err=%s", err)
+		return nil
+	}
+	if cached == nil {
+		return nil
+	}
+
+	var resp Response
+	if err := config.Encoder.Unmarshal(cached, &resp); err != nil {
+		config.Metrics.CacheError()
+		c.Logger().Errorf("[echo-cache] Failed to unmarshal response, err=%s", err)
+		return nil
+	}
+	return &resp
+}
+
+// saveResponse saves resp under key and reports whether it succeeded,
+// errors are logged.
+func (m *cacheMiddleware) saveResponse(c echo.Context, key string, resp *Response, ttl time.Duration) bool {
+	config := &m.config
+	b, err := config.Encoder.Marshal(resp)
+	if err != nil {
+		c.Logger().Errorf("[echo-cache] Failed to marshal response, err=%s", err)
+		return false
+	}
+
+	ctx, cancel := m.storeContext(c)
+	defer cancel()
+	if err = m.store.SetContext(ctx, key, b, ttl); err != nil {
+		c.Logger().Errorf("[echo-cache] Failed to save cache, key=%s err=%s", key, err)
+		return false
+	}
+	m.reportSize(c, len(b))
+	return true
+}
+
+// reportSize reports the size of the store after saving a response of size
+// bytes. With `StoreSizeInterval`, the size of stores implementing
+// `store.PrefixSizer` or `store.Inspector` is read in background at most
+// every interval, the size of the saved response is reported otherwise.
+func (m *cacheMiddleware) reportSize(c echo.Context, size int) {
+	config := &m.config
+	_, sizer := config.Store.(store.PrefixSizer)
+	_, inspector := config.Store.(store.Inspector)
+	if config.StoreSizeInterval <= 0 || (!sizer && !inspector) || m.sizeUnsupported.Load() {
+		config.Metrics.CacheSize(float64(size))
+		return
+	}
+
+	now := time.Now().UnixNano()
+	last := m.sizeReportedAt.Load()
+	if now-last < int64(config.StoreSizeInterval) || !m.sizeReportedAt.CompareAndSwap(last, now) {
+		return
+	}
+	logger := c.Logger()
+	go func() {
+		total, err := m.storeSize()
+		if errors.Is(err, store.ErrNotSupported) {
+			// e.g. a wrapper of a store which can't tell
+			m.sizeUnsupported.Store(true)
+			return
+		}
+		if err != nil {
+			config.Metrics.CacheError()
+			logger.Errorf("[echo-cache] Failed to read cache size, err=%s", err)
+			return
+		}
+		config.Metrics.CacheSize(float64(total))
+	}()
+}
+
+// storeSize returns the size of the keys of `CachePrefix` when the store can
+// tell, like `Clear` they start with `CachePrefix + "-"`, and the size of
+// the whole store otherwise.
+func (m *cacheMiddleware) storeSize() (int64, error) {
+	config := &m.config
+	if ps, ok := config.Store.(store.PrefixSizer); ok {
+		size, err := ps.SizeBytesPrefix(config.CachePrefix + "-")
+		if !errors.Is(err, store.ErrNotSupported) {
+			return size, err
+		}
+	}
+	if is, ok := config.Store.(store.Inspector); ok {
+		return is.SizeBytes()
+	}
+	return 0, store.ErrNotSupported
+}
+
+// serve writes a cached response, or 304 when the validators of the
+// conditional request match, or the requested ranges. It returns false when nothing was written
+// because the streamed body is gone.
+func (m *cacheMiddleware) serve(c echo.Context, resp *Response) bool {
+	resp, ok := m.represent(c, resp)
+	if !ok {
+		return false
+	}
+	if notModified(c.Request(), resp) {
+		writeNotModified(c, resp)
+		return true
+	}
+	if rangeRequest(c.Request(), resp) {
+		if resp.BodyKey != "" {
+			return m.serveStreamRange(c, resp)
+		}
+		serveRange(c, resp, bytes.NewReader(resp.Body))
+		return true
+	}
+	return m.write(c, resp)
+}
+
+// write writes a cached response, reading the body from the stream store
+// when it was saved in chunks. It returns false when the body is gone.
+func (m *cacheMiddleware) write(c echo.Context, resp *Response) bool {
+	resp, ok := m.represent(c, resp)
+	if !ok {
+		return false
+	}
+	if c.Request().Method == http.MethodHead {
+		writeHead(c, resp)
+		return true
+	}
+	if resp.BodyKey == "" {
+		writeResponse(c, resp)
+		return true
+	}
+
+	body, ok := m.openBody(c, resp)
+	if !ok {
+		return false
+	}
+	defer body.Close()
+	writeStream(c, resp, body)
+	return true
+}
+
+// openBody returns the reader of the streamed body of resp, which is read
+// until the request is done. Opening it is bounded by `StoreTimeout`,
+// errors are logged and reported as not ok like a missing body.
+func (m *cacheMiddleware) openBody(c echo.Context, resp *Response) (io.ReadCloser, bool) {
+	if m.streams == nil {
+		return nil, false
+	}
+	ctx, cancel := context.WithCancel(c.Request().Context())
+	var timer *time.Timer
+	if m.config.StoreTimeout > 0 {
+		timer = time.AfterFunc(m.config.StoreTimeout, cancel)
+	}
+	body, err := store.GetStreamContext(ctx, m.streams, resp.BodyKey)
+	if timer != nil && !timer.Stop() && err == nil && body != nil {
+		// the timeout ended the reads of the body
+		body.Close()
+		err = context.DeadlineExceeded
+	}
+	if err != nil || body == nil {
+		cancel()
+		if err != nil {
+			m.config.Metrics.CacheError()
+			c.Logger().Errorf("[echo-cache] Failed to get response body, key=%s err=%s", resp.BodyKey, err)
+		}
+		return nil, false
+	}
+
+	sb := &streamBody{body, cancel}
+	if seeker, ok := body.(io.Seeker); ok {
+		return seekableBody{sb, seeker}, true
+	}
+	return sb, true
+}
+
+// streamBody cancels the reads of a streamed body once closed
+type streamBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *streamBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// seekableBody keeps the io.Seeker of a streamed body
+type seekableBody struct {
+	*streamBody
+	io.Seeker
+}
+
+// represent returns the representation of resp negotiated with the client,
+// errors are logged and reported as not ok.
+func (m *cacheMiddleware) represent(c echo.Context, resp *Response) (*Response, bool) {
+	rep, err := represent(c, resp)
+	if err != nil {
+		m.config.Metrics.CacheError()
+		c.Logger().Errorf("[echo-cache] Failed to decompress response, err=%s", err)
+		return nil, false
+	}
+	return rep, true
+}
+
+// writeHead writes the status and headers of a cached response
+// without the body, in answer to a HEAD request
+func writeHead(c echo.Context, resp *Response) {
+	header := c.Response().Header()
+	maps.Copy(header, resp.Headers)
+	// the length of a streamed body is only known when the handler set it
+	if resp.BodyKey == "" {
+		header.Set(echo.HeaderContentLength, strconv.Itoa(len(resp.Body)))
+	}
+	c.Response().WriteHeader(resp.StatusCode)
+}
+
+// writeStream writes a cached response with its streamed body
+func writeStream(c echo.Context, resp *Response, body io.Reader) {
+	maps.Copy(c.Response().Header(), resp.Headers)
+	c.Response().WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(c.Response(), body); err != nil {
+		c.Logger().Errorf("[echo-cache] Failed to write response, err=%s", err)
+	}
+}
+
+func writeResponse(c echo.Context, resp *Response) {
+	maps.Copy(c.Response().Header(), resp.Headers)
+	c.Response().WriteHeader(resp.StatusCode)
+	if _, err := c.Response().Write(resp.Body); err != nil {
+		c.Logger().Errorf("[echo-cache] Failed to write response, err=%s", err)
 	}
 }
 
@@ -1,8 +1,16 @@
 package cache
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -11,6 +19,9 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/sdvcrx/echo-cache/store"
+	compressstore "github.com/sdvcrx/echo-cache/store/compress"
+	memorystore "github.com/sdvcrx/echo-cache/store/memory"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
@@ -35,6 +46,11 @@ func (da *dumyStore) Set(key string, val []byte, ttl time.Duration) error {
 	return args.Error(0)
 }
 
+func (da *dumyStore) Delete(key string) error {
+	args := da.Called(key)
+	return args.Error(0)
+}
+
 type memoryStore struct {
 	data sync.Map
 }
@@ -52,6 +68,27 @@ func (m *memoryStore) Set(key string, val []byte, ttl time.Duration) error {
 	return nil
 }
 
+func (m *memoryStore) Delete(key string) error {
+	m.data.Delete(key)
+	return nil
+}
+
+// ttlStore records the ttl of the values
+type ttlStore struct {
+	memoryStore
+	ttls sync.Map
+}
+
+func (s *ttlStore) Set(key string, val []byte, ttl time.Duration) error {
+	s.ttls.Store(key, ttl)
+	return s.memoryStore.Set(key, val, ttl)
+}
+
+func (s *ttlStore) ttl(key string) time.Duration {
+	ttl, _ := s.ttls.Load(key)
+	return ttl.(time.Duration)
+}
+
 func createDumpStore(cacheKey string) *dumyStore {
 	store := new(dumyStore)
 	if cacheKey != "" {
@@ -114,13 +151,13 @@ func (suite *middlewareTestSuite) TestDefaultSkipper() {
 		suite.testSkipper(DefaultCacheSkipper, c, true)
 	})
 
-	suite.Run("Skip req with range header", func() {
+	suite.Run("Don't skip req with range header", func() {
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		req.Header.Set("Range", "bytes=0-1023")
 		rec := httptest.NewRecorder()
 		c := suite.e.NewContext(req, rec)
 
-		suite.testSkipper(DefaultCacheSkipper, c, true)
+		suite.testSkipper(DefaultCacheSkipper, c, false)
 	})
 }
 
@@ -323,6 +360,1347 @@ func (suite *middlewareTestSuite) TestGetCacheError() {
 	store.AssertCalled(suite.T(), "Set", key, mock.Anything, mock.Anything)
 }
 
+func (suite *middlewareTestSuite) TestRespectResponseCacheControl() {
+	suite.Run("Save with max-age", func() {
+		c, _ := createEchoContext(suite.e, "/")
+		store := createDumpStore("")
+		store.On("Get", "key").Return(([]byte)(nil), nil)
+		store.On("Set", "key", mock.Anything, 30*time.Second).Return(nil)
+
+		handler := func(c echo.Context) error {
+			c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=30")
+			return c.String(http.StatusOK, "OK")
+		}
+		middleware := CacheWithConfig(CacheConfig{
+			Store:                       store,
+			CacheKey:                    suite.testCacheKey,
+			CacheDuration:               time.Hour,
+			RespectResponseCacheControl: true,
+		})
+		suite.NoError(middleware(handler)(c))
+
+		store.AssertCalled(suite.T(), "Set", "key", mock.Anything, 30*time.Second)
+	})
+
+	suite.Run("Skip no-store", func() {
+		c, rec := createEchoContext(suite.e, "/")
+		store := createDumpStore("key")
+
+		handler := func(c echo.Context) error {
+			c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+			return c.String(http.StatusOK, "OK")
+		}
+		middleware := CacheWithConfig(CacheConfig{
+			Store:                       store,
+			CacheKey:                    suite.testCacheKey,
+			RespectResponseCacheControl: true,
+		})
+		suite.NoError(middleware(handler)(c))
+
+		suite.Equal("OK", rec.Body.String())
+		store.AssertNotCalled(suite.T(), "Set", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func (suite *middlewareTestSuite) TestRespectRequestCacheControl() {
+	newRequest := func(cacheControl string) (echo.Context, *httptest.ResponseRecorder) {
+		c, rec := createEchoContext(suite.e, "/")
+		c.Request().Header.Set(echo.HeaderCacheControl, cacheControl)
+		return c, rec
+	}
+	newMiddleware := func(store *memoryStore) echo.MiddlewareFunc {
+		return CacheWithConfig(CacheConfig{
+			Store:                      store,
+			Encoder:                    suite.enc,
+			CacheKey:                   suite.testCacheKey,
+			RespectRequestCacheControl: true,
+		})
+	}
+	saveResponse := func(store *memoryStore, resp *Response) {
+		b, err := suite.enc.Marshal(resp)
+		suite.NoError(err)
+		suite.NoError(store.Set("key", b, 0))
+	}
+	calls := 0
+	handler := func(c echo.Context) error {
+		calls++
+		return c.String(http.StatusOK, "FRESH")
+	}
+
+	suite.Run("no-cache skips lookup", func() {
+		calls = 0
+		store := &memoryStore{}
+		saveResponse(store, NewResponse(http.StatusOK, nil, []byte("CACHED")))
+
+		c, rec := newRequest("no-cache")
+		suite.NoError(newMiddleware(store)(handler)(c))
+		suite.Equal(1, calls)
+		suite.Equal("FRESH", rec.Body.String())
+
+		// fresh response replaced the cached one
+		c, rec = newRequest("")
+		suite.NoError(newMiddleware(store)(handler)(c))
+		suite.Equal(1, calls)
+		suite.Equal("FRESH", rec.Body.String())
+	})
+
+	suite.Run("no-store skips saving", func() {
+		calls = 0
+		store := &memoryStore{}
+
+		c, _ := newRequest("no-store")
+		suite.NoError(newMiddleware(store)(handler)(c))
+		suite.Equal(1, calls)
+
+		b, _ := store.Get("key")
+		suite.Nil(b)
+	})
+
+	suite.Run("max-age rejects older entries", func() {
+		calls = 0
+		store := &memoryStore{}
+		resp := NewResponse(http.StatusOK, nil, []byte("CACHED"))
+		resp.CreatedAt = time.Now().Add(-time.Minute).UnixMilli()
+		saveResponse(store, resp)
+
+		c, rec := newRequest("max-age=3600")
+		suite.NoError(newMiddleware(store)(handler)(c))
+		suite.Equal(0, calls)
+		suite.Equal("CACHED", rec.Body.String())
+
+		c, rec = newRequest("max-age=10")
+		suite.NoError(newMiddleware(store)(handler)(c))
+		suite.Equal(1, calls)
+		suite.Equal("FRESH", rec.Body.String())
+	})
+
+	suite.Run("only-if-cached responds 504 on miss", func() {
+		calls = 0
+		store := &memoryStore{}
+
+		c, _ := newRequest("only-if-cached")
+		err := newMiddleware(store)(handler)(c)
+		suite.ErrorIs(err, echo.ErrGatewayTimeout)
+		suite.Equal(0, calls)
+	})
+}
+
+func (suite *middlewareTestSuite) TestVary() {
+	calls := 0
+	handler := func(c echo.Context) error {
+		calls++
+		vary := c.Get("vary").(string)
+		c.Response().Header().Set(echo.HeaderVary, vary)
+		return c.String(http.StatusOK, c.Request().Header.Get("Accept-Language"))
+	}
+	request := func(middleware echo.MiddlewareFunc, vary, lang string) string {
+		c, rec := createEchoContext(suite.e, "/")
+		c.Set("vary", vary)
+		c.Request().Header.Set("Accept-Language", lang)
+		suite.NoError(middleware(handler)(c))
+		return rec.Body.String()
+	}
+
+	suite.Run("Cache variants", func() {
+		calls = 0
+		store := &memoryStore{}
+		middleware := CacheWithConfig(CacheConfig{
+			Store:    store,
+			CacheKey: suite.testCacheKey,
+		})
+
+		suite.Equal("en", request(middleware, "accept-language", "en"))
+		suite.Equal("fr", request(middleware, "accept-language", "fr"))
+		suite.Equal("en", request(middleware, "accept-language", "en"))
+		suite.Equal("fr", request(middleware, "accept-language", "fr"))
+		suite.Equal(2, calls)
+
+		b, _ := store.Get("key-vary-Accept-Language=en")
+		suite.NotNil(b)
+	})
+
+	suite.Run("Keep variants on no-cache", func() {
+		calls = 0
+		store := &memoryStore{}
+		middleware := CacheWithConfig(CacheConfig{
+			Store:                      store,
+			CacheKey:                   suite.testCacheKey,
+			RespectRequestCacheControl: true,
+		})
+
+		suite.Equal("en", request(middleware, "accept-language", "en"))
+		c, _ := createEchoContext(suite.e, "/")
+		c.Set("vary", "accept-language")
+		c.Request().Header.Set("Accept-Language", "fr")
+		c.Request().Header.Set("Cache-Control", "no-cache")
+		suite.NoError(middleware(handler)(c))
+		suite.Equal(2, calls)
+
+		suite.Equal("en", request(middleware, "accept-language", "en"))
+		suite.Equal("fr", request(middleware, "accept-language", "fr"))
+		suite.Equal(2, calls)
+	})
+
+	suite.Run("Extend the index to outlive variants", func() {
+		store := &ttlStore{}
+		short := CacheWithConfig(CacheConfig{Store: store, CacheKey: suite.testCacheKey, CacheDuration: time.Minute})
+		long := CacheWithConfig(CacheConfig{Store: store, CacheKey: suite.testCacheKey, CacheDuration: time.Hour})
+
+		request(short, "accept-language", "en")
+		suite.Equal(time.Minute, store.ttl("key"))
+		request(long, "accept-language", "fr")
+		suite.Equal(time.Hour, store.ttl("key"))
+		request(short, "accept-language", "de")
+		suite.Equal(time.Hour, store.ttl("key"))
+	})
+
+	suite.Run("Vary * is uncacheable", func() {
+		calls = 0
+		store := &memoryStore{}
+		middleware := CacheWithConfig(CacheConfig{
+			Store:    store,
+			CacheKey: suite.testCacheKey,
+		})
+
+		suite.Equal("en", request(middleware, "*", "en"))
+		suite.Equal("en", request(middleware, "*", "en"))
+		suite.Equal(2, calls)
+	})
+}
+
+func (suite *middlewareTestSuite) TestConditionalRequest() {
+	lastModified := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	handler := func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderLastModified, lastModified.Format(http.TimeFormat))
+		return c.String(http.StatusOK, "OK")
+	}
+	middleware := CacheWithConfig(CacheConfig{
+		Store:        &memoryStore{},
+		CacheKey:     suite.testCacheKey,
+		GenerateETag: true,
+	})
+	request := func(header http.Header) *httptest.ResponseRecorder {
+		c, rec := createEchoContext(suite.e, "/")
+		maps.Copy(c.Request().Header, header)
+		suite.NoError(middleware(handler)(c))
+		return rec
+	}
+
+	// save response
+	rec := request(nil)
+	suite.Empty(rec.Header().Get("ETag"))
+
+	rec = request(nil)
+	etag := rec.Header().Get("ETag")
+	suite.Equal(generateETag([]byte("OK")), etag)
+	suite.Equal("OK", rec.Body.String())
+
+	suite.Run("If-None-Match", func() {
+		rec := request(http.Header{"If-None-Match": {`"other", ` + etag}})
+		suite.Equal(http.StatusNotModified, rec.Code)
+		suite.Equal(etag, rec.Header().Get("ETag"))
+		suite.Empty(rec.Body.String())
+
+		rec = request(http.Header{"If-None-Match": {`"other"`}})
+		suite.Equal(http.StatusOK, rec.Code)
+		suite.Equal("OK", rec.Body.String())
+	})
+
+	suite.Run("If-Modified-Since", func() {
+		rec := request(http.Header{echo.HeaderIfModifiedSince: {lastModified.Format(http.TimeFormat)}})
+		suite.Equal(http.StatusNotModified, rec.Code)
+		suite.Empty(rec.Body.String())
+
+		since := lastModified.Add(-time.Hour).Format(http.TimeFormat)
+		rec = request(http.Header{echo.HeaderIfModifiedSince: {since}})
+		suite.Equal(http.StatusOK, rec.Code)
+		suite.Equal("OK", rec.Body.String())
+	})
+}
+
+func (suite *middlewareTestSuite) TestCoalesceRequests() {
+	run := func(timeout time.Duration, waiters int, header http.Header) (calls int32, bodies []string) {
+		entered := make(chan struct{}, waiters+1)
+		release := make(chan struct{})
+		handler := func(c echo.Context) error {
+			atomic.AddInt32(&calls, 1)
+			entered <- struct{}{}
+			<-release
+			return c.String(http.StatusOK, "OK")
+		}
+		middleware := CacheWithConfig(CacheConfig{
+			Store:            &memoryStore{},
+			CacheKey:         suite.testCacheKey,
+			CoalesceRequests: true,
+			CoalesceTimeout:  timeout,
+		})
+
+		var wg sync.WaitGroup
+		recs := make([]*httptest.ResponseRecorder, waiters+1)
+		request := func(i int) {
+			defer wg.Done()
+			c, rec := createEchoContext(suite.e, "/")
+			if i > 0 {
+				maps.Copy(c.Request().Header, header)
+			}
+			recs[i] = rec
+			suite.NoError(middleware(handler)(c))
+		}
+
+		wg.Add(1)
+		go request(0)
+		<-entered
+		for i := 1; i <= waiters; i++ {
+			wg.Add(1)
+			go request(i)
+		}
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		for _, rec := range recs {
+			bodies = append(bodies, rec.Body.String())
+		}
+		return calls, bodies
+	}
+
+	suite.Run("Waiters are served the leader's response", func() {
+		calls, bodies := run(0, 5, nil)
+		suite.Equal(int32(1), calls)
+		suite.Equal([]string{"OK", "OK", "OK", "OK", "OK", "OK"}, bodies)
+	})
+
+	suite.Run("Range waiters are served a part of the leader's response", func() {
+		calls, bodies := run(0, 2, http.Header{"Range": {"bytes=1-"}})
+		suite.Equal(int32(1), calls)
+		suite.Equal([]string{"OK", "K", "K"}, bodies)
+	})
+
+	suite.Run("Waiters run the handler after timeout", func() {
+		calls, bodies := run(10*time.Millisecond, 2, nil)
+		suite.Equal(int32(3), calls)
+		suite.Equal([]string{"OK", "OK", "OK"}, bodies)
+	})
+}
+
+func (suite *middlewareTestSuite) TestStaleWhileRevalidate() {
+	var calls int32
+	release := make(chan struct{})
+	handler := func(c echo.Context) error {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return c.String(http.StatusOK, "FRESH")
+	}
+	store := &memoryStore{}
+	middleware := CacheWithConfig(CacheConfig{
+		Store:                store,
+		Encoder:              suite.enc,
+		CacheKey:             suite.testCacheKey,
+		CacheDuration:        time.Minute,
+		StaleWhileRevalidate: time.Minute,
+	})
+	request := func() string {
+		c, rec := createEchoContext(suite.e, "/")
+		suite.NoError(middleware(handler)(c))
+		return rec.Body.String()
+	}
+
+	now := time.Now()
+	resp := NewResponse(http.StatusOK, nil, []byte("STALE"))
+	resp.ExpiresAt = now.Add(-time.Second).UnixMilli()
+	resp.StaleUntil = now.Add(time.Minute).UnixMilli()
+	b, err := suite.enc.Marshal(resp)
+	suite.NoError(err)
+	suite.NoError(store.Set("key", b, 0))
+
+	// only one refresh runs at a time
+	suite.Equal("STALE", request())
+	suite.Equal("STALE", request())
+	close(release)
+
+	suite.Eventually(func() bool {
+		b, _ := store.Get("key")
+		var resp Response
+		return suite.enc.Unmarshal(b, &resp) == nil && string(resp.Body) == "FRESH"
+	}, time.Second, 10*time.Millisecond)
+	suite.Equal(int32(1), atomic.LoadInt32(&calls))
+	suite.Equal("FRESH", request())
+
+	suite.Run("Miss when stale period is over", func() {
+		resp.StaleUntil = now.Add(-time.Millisecond).UnixMilli()
+		b, err := suite.enc.Marshal(resp)
+		suite.NoError(err)
+		suite.NoError(store.Set("key", b, 0))
+
+		suite.Equal("FRESH", request())
+		suite.Equal(int32(2), atomic.LoadInt32(&calls))
+	})
+
+	suite.Run("Refresh flushing handler", func() {
+		resp.StaleUntil = now.Add(time.Minute).UnixMilli()
+		b, err := suite.enc.Marshal(resp)
+		suite.NoError(err)
+		suite.NoError(store.Set("key", b, 0))
+
+		c, rec := createEchoContext(suite.e, "/")
+		suite.NoError(middleware(func(c echo.Context) error {
+			c.Response().WriteHeader(http.StatusOK)
+			c.Response().Write([]byte("FLUSHED"))
+			c.Response().Flush()
+			return nil
+		})(c))
+		suite.Equal("STALE", rec.Body.String())
+
+		suite.Eventually(func() bool {
+			b, _ := store.Get("key")
+			var resp Response
+			return suite.enc.Unmarshal(b, &resp) == nil && string(resp.Body) == "FLUSHED"
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func (suite *middlewareTestSuite) TestStaleIfError() {
+	store := &memoryStore{}
+	middleware := CacheWithConfig(CacheConfig{
+		Store:         store,
+		Encoder:       suite.enc,
+		CacheKey:      suite.testCacheKey,
+		CacheDuration: time.Minute,
+		StaleIfError:  time.Minute,
+	})
+	request := func(handler echo.HandlerFunc) *httptest.ResponseRecorder {
+		c, rec := createEchoContext(suite.e, "/")
+		suite.NoError(middleware(handler)(c))
+		return rec
+	}
+
+	now := time.Now()
+	resp := NewResponse(http.StatusOK, http.Header{"X-Resp": {"STALE"}}, []byte("STALE"))
+	resp.ExpiresAt = now.Add(-time.Second).UnixMilli()
+	resp.StaleIfErrorUntil = now.Add(time.Minute).UnixMilli()
+	b, err := suite.enc.Marshal(resp)
+	suite.NoError(err)
+	suite.NoError(store.Set("key", b, 0))
+
+	suite.Run("Serve stale on error", func() {
+		rec := request(func(c echo.Context) error {
+			return errors.New("upstream failed")
+		})
+		suite.Equal(http.StatusOK, rec.Code)
+		suite.Equal("STALE", rec.Body.String())
+		suite.Equal("STALE", rec.Header().Get("X-Resp"))
+		suite.Contains(rec.Header().Get("Warning"), "111")
+	})
+
+	suite.Run("Serve stale on 5xx", func() {
+		rec := request(func(c echo.Context) error {
+			return c.String(http.StatusBadGateway, "ERROR")
+		})
+		suite.Equal(http.StatusOK, rec.Code)
+		suite.Equal("STALE", rec.Body.String())
+	})
+
+	suite.Run("Pass through 4xx", func() {
+		rec := request(func(c echo.Context) error {
+			return c.String(http.StatusNotFound, "NOT FOUND")
+		})
+		suite.Equal(http.StatusNotFound, rec.Code)
+		suite.Equal("NOT FOUND", rec.Body.String())
+	})
+
+	suite.Run("Serve stale on timeout", func() {
+		middleware := CacheWithConfig(CacheConfig{
+			Store:               store,
+			Encoder:             suite.enc,
+			CacheKey:            suite.testCacheKey,
+			StaleIfErrorTimeout: 10 * time.Millisecond,
+		})
+		c, rec := createEchoContext(suite.e, "/")
+		suite.NoError(middleware(func(c echo.Context) error {
+			<-c.Request().Context().Done()
+			return c.Request().Context().Err()
+		})(c))
+		suite.Equal(http.StatusOK, rec.Code)
+		suite.Equal("STALE", rec.Body.String())
+		suite.NoError(c.Request().Context().Err())
+	})
+
+	suite.Run("Save fresh response", func() {
+		rec := request(func(c echo.Context) error {
+			c.Response().Header().Set("X-Resp", "FRESH")
+			return c.String(http.StatusOK, "FRESH")
+		})
+		suite.Equal("FRESH", rec.Body.String())
+		suite.Equal("FRESH", rec.Header().Get("X-Resp"))
+		suite.Empty(rec.Header().Get("Warning"))
+
+		rec = request(suite.handler)
+		suite.Equal("FRESH", rec.Body.String())
+	})
+
+	suite.Run("Flushing handler", func() {
+		suite.NoError(store.Set("key", b, 0))
+		rec := request(func(c echo.Context) error {
+			c.Response().WriteHeader(http.StatusBadGateway)
+			c.Response().Write([]byte("ERR"))
+			c.Response().Flush()
+			return nil
+		})
+		suite.Equal("STALE", rec.Body.String())
+
+		rec = request(func(c echo.Context) error {
+			c.Response().WriteHeader(http.StatusOK)
+			c.Response().Write([]byte("FLUSHED"))
+			c.Response().Flush()
+			return nil
+		})
+		suite.Equal("FLUSHED", rec.Body.String())
+		suite.Equal("FLUSHED", request(suite.handler).Body.String())
+	})
+}
+
+func (suite *middlewareTestSuite) TestPurge() {
+	calls := 0
+	handler := func(c echo.Context) error {
+		calls++
+		c.Response().Header().Set(echo.HeaderVary, c.QueryParam("vary"))
+		return c.String(http.StatusOK, "OK")
+	}
+	config := CacheConfig{
+		Store: &memoryStore{},
+	}
+	middleware := CacheWithConfig(config)
+	request := func(url string, lang string) {
+		c, _ := createEchoContext(suite.e, url)
+		c.Request().Header.Set("Accept-Language", lang)
+		suite.NoError(middleware(handler)(c))
+	}
+
+	suite.Run("Purge response", func() {
+		calls = 0
+		request("/posts?page=1", "")
+		request("/posts?page=1", "")
+		suite.Equal(1, calls)
+
+		suite.NoError(config.Purge(http.MethodGet, "/posts?page=1"))
+		request("/posts?page=1", "")
+		suite.Equal(2, calls)
+	})
+
+	suite.Run("Purge variants", func() {
+		calls = 0
+		url := "/posts?vary=Accept-Language"
+		request(url, "en")
+		request(url, "fr")
+		suite.Equal(2, calls)
+
+		suite.NoError(config.Purge(http.MethodGet, url))
+		time.Sleep(2 * time.Millisecond)
+		request(url, "en")
+		request(url, "fr")
+		suite.Equal(4, calls)
+	})
+
+	suite.Run("Store is required", func() {
+		err := CacheConfig{}.Purge(http.MethodGet, "/")
+		suite.ErrorIs(err, ErrStoreRequired)
+	})
+}
+
+func (suite *middlewareTestSuite) TestClear() {
+	calls := 0
+	handler := func(c echo.Context) error {
+		calls++
+		return c.String(http.StatusOK, "OK")
+	}
+	store := memorystore.New(1024)
+	app1 := CacheConfig{Store: store, CachePrefix: "app1"}
+	app2 := CacheConfig{Store: store, CachePrefix: "app2"}
+	request := func(config CacheConfig, url string) {
+		c, _ := createEchoContext(suite.e, url)
+		suite.NoError(CacheWithConfig(config)(handler)(c))
+	}
+
+	for _, config := range []CacheConfig{app1, app2} {
+		request(config, "/posts")
+		request(config, "/posts?page=2")
+	}
+	suite.Equal(4, calls)
+
+	suite.NoError(app1.Clear())
+	for _, config := range []CacheConfig{app1, app2} {
+		request(config, "/posts")
+		request(config, "/posts?page=2")
+	}
+	suite.Equal(6, calls)
+
+	suite.Run("Store doesn't support clear", func() {
+		err := CacheConfig{Store: &memoryStore{}}.Clear()
+		suite.ErrorIs(err, ErrClearNotSupported)
+	})
+
+	suite.Run("Wrapped store doesn't support clear", func() {
+		wrapped := compressstore.New(compressstore.CompressStoreOption{Store: &memoryStore{}})
+		err := CacheConfig{Store: wrapped}.Clear()
+		suite.ErrorIs(err, ErrClearNotSupported)
+	})
+}
+
+func (suite *middlewareTestSuite) TestPurgeTag() {
+	calls := 0
+	handler := func(c echo.Context) error {
+		calls++
+		if c.Path() == "/header" {
+			c.Response().Header().Set(HeaderSurrogateKey, "product:1 product:2")
+		} else {
+			AddTags(c, "product:2")
+		}
+		return c.String(http.StatusOK, "OK")
+	}
+	config := CacheConfig{
+		Store: memorystore.New(1024),
+	}
+	middleware := CacheWithConfig(config)
+	request := func(path string) *httptest.ResponseRecorder {
+		c, rec := createEchoContext(suite.e, path)
+		c.SetPath(path)
+		suite.NoError(middleware(handler)(c))
+		return rec
+	}
+
+	request("/header")
+	request("/context")
+	suite.Empty(request("/header").Header().Get(HeaderSurrogateKey))
+	suite.Equal(2, calls)
+
+	suite.NoError(config.PurgeTag("product:1"))
+	request("/header")
+	request("/context")
+	suite.Equal(3, calls)
+
+	suite.NoError(config.PurgeTag("product:2"))
+	request("/header")
+	request("/context")
+	suite.Equal(5, calls)
+
+	suite.Run("Store doesn't support tags", func() {
+		err := CacheConfig{Store: &memoryStore{}}.PurgeTag("product:1")
+		suite.ErrorIs(err, ErrTagsNotSupported)
+	})
+
+	suite.Run("Wrapped store doesn't support tags", func() {
+		metrics := &errorMetrics{}
+		config := CacheConfig{
+			Store:   compressstore.New(compressstore.CompressStoreOption{Store: &memoryStore{}}),
+			Metrics: metrics,
+		}
+		c, _ := createEchoContext(suite.e, "/header")
+		c.SetPath("/header")
+		suite.NoError(CacheWithConfig(config)(handler)(c))
+		suite.Equal(int32(0), metrics.errors.Load())
+
+		suite.ErrorIs(config.PurgeTag("product:1"), ErrTagsNotSupported)
+	})
+}
+
+// slowStore blocks every operation until the context is done
+type slowStore struct {
+	memoryStore
+}
+
+func (s *slowStore) GetContext(ctx context.Context, key string) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (s *slowStore) SetContext(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *slowStore) DeleteContext(ctx context.Context, key string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// slowStreamStore blocks tagging and streams until the context is done
+type slowStreamStore struct {
+	streamStore
+}
+
+func (s *slowStreamStore) Tag(key string, tags []string, ttl time.Duration) error {
+	return s.TagContext(context.Background(), key, tags, ttl)
+}
+
+func (s *slowStreamStore) TagContext(ctx context.Context, key string, tags []string, ttl time.Duration) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *slowStreamStore) PurgeTag(tag string) error {
+	return nil
+}
+
+func (s *slowStreamStore) SetStreamContext(ctx context.Context, key string, r io.Reader, ttl time.Duration) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *slowStreamStore) GetStreamContext(ctx context.Context, key string) (io.ReadCloser, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (suite *middlewareTestSuite) TestStoreTimeout() {
+	c, rec := createEchoContext(suite.e, "/")
+	middleware := CacheWithConfig(CacheConfig{
+		Store:        &slowStore{},
+		StoreTimeout: 10 * time.Millisecond,
+	})
+
+	start := time.Now()
+	suite.NoError(middleware(suite.handler)(c))
+	suite.Less(time.Since(start), time.Second)
+	suite.Equal("OK", rec.Body.String())
+
+	suite.Run("Tags and streamed bodies", func() {
+		calls := 0
+		chunk := strings.Repeat("a", 1024)
+		handler := func(c echo.Context) error {
+			calls++
+			c.Response().Header().Set(HeaderSurrogateKey, "product")
+			c.Response().WriteHeader(http.StatusOK)
+			for range 4 {
+				c.Response().Write([]byte(chunk))
+			}
+			return nil
+		}
+		store := &slowStreamStore{}
+		config := CacheConfig{
+			Store:           &store.streamStore,
+			CacheKey:        suite.testCacheKey,
+			StreamThreshold: SizeKB,
+			StoreTimeout:    10 * time.Millisecond,
+		}
+		// saved without the slow operations
+		c, _ := createEchoContext(suite.e, "/")
+		suite.NoError(CacheWithConfig(config)(handler)(c))
+
+		config.Store = store
+		middleware := CacheWithConfig(config)
+		start := time.Now()
+		// the body can't be read and is fetched again,
+		// the store falls behind the new body which isn't saved
+		c, rec := createEchoContext(suite.e, "/")
+		suite.NoError(middleware(handler)(c))
+		suite.Less(time.Since(start), time.Second)
+		suite.Equal(2, calls)
+		suite.Equal(strings.Repeat(chunk, 4), rec.Body.String())
+
+		// a small body is saved, tagging it times out
+		config.StreamThreshold = 8 * SizeKB
+		config.CacheKey = func(prefix string, req *http.Request) string { return "small" }
+		start = time.Now()
+		c, _ = createEchoContext(suite.e, "/")
+		suite.NoError(CacheWithConfig(config)(handler)(c))
+		suite.Less(time.Since(start), time.Second)
+		v, _ := store.Get("small")
+		suite.NotNil(v)
+	})
+}
+
+func (suite *middlewareTestSuite) TestRangeRequest() {
+	lastModified := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	calls := 0
+	handler := func(c echo.Context) error {
+		calls++
+		c.Response().Header().Set(echo.HeaderLastModified, lastModified.Format(http.TimeFormat))
+		c.Response().Header().Set("ETag", `"v1"`)
+		return c.Blob(http.StatusOK, echo.MIMETextPlain, []byte("0123456789"))
+	}
+	middleware := CacheWithConfig(CacheConfig{
+		Store:    &memoryStore{},
+		CacheKey: suite.testCacheKey,
+	})
+	request := func(header http.Header) *httptest.ResponseRecorder {
+		c, rec := createEchoContext(suite.e, "/")
+		maps.Copy(c.Request().Header, header)
+		suite.NoError(middleware(handler)(c))
+		return rec
+	}
+
+	// the handler ignores the range, its full response is cached
+	rec := request(http.Header{"Range": {"bytes=0-3"}})
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Equal("0123456789", rec.Body.String())
+
+	suite.Run("Single range", func() {
+		rec := request(http.Header{"Range": {"bytes=2-5"}})
+		suite.Equal(http.StatusPartialContent, rec.Code)
+		suite.Equal("bytes 2-5/10", rec.Header().Get("Content-Range"))
+		suite.Equal("2345", rec.Body.String())
+	})
+
+	suite.Run("Multiple ranges", func() {
+		rec := request(http.Header{"Range": {"bytes=0-1,8-"}})
+		suite.Equal(http.StatusPartialContent, rec.Code)
+		suite.Contains(rec.Header().Get(echo.HeaderContentType), "multipart/byteranges")
+		suite.Contains(rec.Body.String(), "Content-Range: bytes 0-1/10")
+		suite.Contains(rec.Body.String(), "Content-Range: bytes 8-9/10")
+	})
+
+	suite.Run("Unsatisfiable range", func() {
+		rec := request(http.Header{"Range": {"bytes=20-"}})
+		suite.Equal(http.StatusRequestedRangeNotSatisfiable, rec.Code)
+		suite.Equal("bytes */10", rec.Header().Get("Content-Range"))
+	})
+
+	suite.Run("If-Range", func() {
+		rec := request(http.Header{"Range": {"bytes=0-1"}, "If-Range": {`"v1"`}})
+		suite.Equal(http.StatusPartialContent, rec.Code)
+		suite.Equal("01", rec.Body.String())
+
+		rec = request(http.Header{"Range": {"bytes=0-1"}, "If-Range": {`"v0"`}})
+		suite.Equal(http.StatusOK, rec.Code)
+		suite.Equal("0123456789", rec.Body.String())
+
+		rec = request(http.Header{"Range": {"bytes=0-1"}, "If-Range": {lastModified.Format(http.TimeFormat)}})
+		suite.Equal(http.StatusPartialContent, rec.Code)
+	})
+
+	suite.Equal(1, calls)
+
+	suite.Run("Partial response on miss", func() {
+		calls := 0
+		content := []byte("0123456789")
+		middleware := CacheWithConfig(CacheConfig{Store: &memoryStore{}})
+		request := func(header http.Header, cookie bool) *httptest.ResponseRecorder {
+			c, rec := createEchoContext(suite.e, "/partial")
+			maps.Copy(c.Request().Header, header)
+			suite.NoError(middleware(func(c echo.Context) error {
+				calls++
+				if cookie {
+					c.SetCookie(&http.Cookie{Name: "session", Value: "1"})
+				}
+				http.ServeContent(c.Response(), c.Request(), "", time.Time{}, bytes.NewReader(content))
+				return nil
+			})(c))
+			return rec
+		}
+
+		for range 2 {
+			rec := request(http.Header{"Range": {"bytes=2-5"}}, true)
+			suite.Equal(http.StatusPartialContent, rec.Code)
+			suite.Equal("2345", rec.Body.String())
+		}
+		rec := request(http.Header{"Range": {"bytes=2-5"}}, false)
+		suite.Equal(http.StatusPartialContent, rec.Code)
+		suite.Equal(3, calls)
+
+		// filled by the full response
+		request(nil, false)
+		rec = request(http.Header{"Range": {"bytes=2-5"}}, false)
+		suite.Equal(http.StatusPartialContent, rec.Code)
+		suite.Equal("2345", rec.Body.String())
+		suite.Equal(4, calls)
+	})
+}
+
+func (suite *middlewareTestSuite) TestHeadRequest() {
+	calls := 0
+	handler := func(c echo.Context) error {
+		calls++
+		return suite.handler(c)
+	}
+	store := &memoryStore{}
+	middleware := CacheWithConfig(CacheConfig{
+		Store: store,
+	})
+	request := func(method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", nil)
+		rec := httptest.NewRecorder()
+		suite.NoError(middleware(handler)(suite.e.NewContext(req, rec)))
+		return rec
+	}
+	getKey := DefaultCacheKey(DefaultCachePrefix, httptest.NewRequest(http.MethodGet, "/", nil))
+	suite.Equal(getKey, DefaultCacheKey(DefaultCachePrefix, httptest.NewRequest(http.MethodHead, "/", nil)))
+
+	// HEAD miss doesn't save the entry
+	request(http.MethodHead)
+	v, _ := store.Get(getKey)
+	suite.Nil(v)
+
+	request(http.MethodGet)
+	rec := request(http.MethodHead)
+	suite.Equal(2, calls)
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Equal("OK", rec.Header().Get("X-TEST"))
+	suite.Equal("2", rec.Header().Get(echo.HeaderContentLength))
+	suite.Empty(rec.Body.String())
+
+	suite.Equal("OK", request(http.MethodGet).Body.String())
+	suite.Equal(2, calls)
+}
+
+func (suite *middlewareTestSuite) TestDiagnosticHeaders() {
+	failing := false
+	handler := func(c echo.Context) error {
+		if failing {
+			return c.String(http.StatusInternalServerError, "ERROR")
+		}
+		return c.String(http.StatusOK, "OK")
+	}
+	store := &memoryStore{}
+	middleware := CacheWithConfig(CacheConfig{
+		Store:             store,
+		Encoder:           suite.enc,
+		CacheKey:          suite.testCacheKey,
+		CacheDuration:     time.Minute,
+		DiagnosticHeaders: true,
+		DiagnosticsSkipper: func(c echo.Context) bool {
+			return c.Request().Header.Get("X-Debug") != "1"
+		},
+	})
+	request := func(method string, debug bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", nil)
+		if debug {
+			req.Header.Set("X-Debug", "1")
+		}
+		rec := httptest.NewRecorder()
+		suite.NoError(middleware(handler)(suite.e.NewContext(req, rec)))
+		return rec
+	}
+
+	rec := request(http.MethodGet, true)
+	suite.Equal(CacheMiss, rec.Header().Get(HeaderXCache))
+	suite.Equal(`echo-cache; fwd=miss; key="key"`, rec.Header().Get(HeaderCacheStatus))
+	suite.Empty(rec.Header().Get("Age"))
+
+	// not saved with the response
+	b, _ := store.Get("key")
+	var resp Response
+	suite.NoError(suite.enc.Unmarshal(b, &resp))
+	suite.Empty(resp.Headers.Get(HeaderXCache))
+	suite.Empty(resp.Headers.Get(HeaderCacheStatus))
+
+	rec = request(http.MethodGet, true)
+	suite.Equal(CacheHit, rec.Header().Get(HeaderXCache))
+	suite.Equal("0", rec.Header().Get("Age"))
+	suite.Regexp(`^echo-cache; hit; ttl=(59|60); key="key"$`, rec.Header().Get(HeaderCacheStatus))
+
+	suite.Run("Bypass", func() {
+		rec := request(http.MethodPost, true)
+		suite.Equal(CacheBypass, rec.Header().Get(HeaderXCache))
+		suite.Equal("echo-cache; fwd=bypass", rec.Header().Get(HeaderCacheStatus))
+	})
+
+	suite.Run("Untrusted client", func() {
+		rec := request(http.MethodGet, false)
+		suite.Equal("OK", rec.Body.String())
+		suite.Empty(rec.Header().Get(HeaderXCache))
+		suite.Empty(rec.Header().Get(HeaderCacheStatus))
+		suite.Empty(rec.Header().Get("Age"))
+	})
+
+	suite.Run("Stale", func() {
+		now := time.Now()
+		resp := NewResponse(http.StatusOK, nil, []byte("STALE"))
+		resp.CreatedAt = now.Add(-90 * time.Second).UnixMilli()
+		resp.ExpiresAt = now.Add(-30 * time.Second).UnixMilli()
+		resp.StaleIfErrorUntil = now.Add(time.Minute).UnixMilli()
+		b, err := suite.enc.Marshal(resp)
+		suite.NoError(err)
+		suite.NoError(store.Set("key", b, 0))
+
+		failing = true
+		rec := request(http.MethodGet, true)
+		suite.Equal("STALE", rec.Body.String())
+		suite.Equal(CacheStale, rec.Header().Get(HeaderXCache))
+		suite.Equal("90", rec.Header().Get("Age"))
+		suite.Regexp(`^echo-cache; fwd=stale; fwd-status=500; ttl=-3[01]; key="key"$`, rec.Header().Get(HeaderCacheStatus))
+	})
+}
+
+func (suite *middlewareTestSuite) TestCompression() {
+	body := strings.Repeat("hello ", 200)
+	handler := func(c echo.Context) error {
+		if c.Request().URL.Path == "/small" {
+			return c.String(http.StatusOK, "OK")
+		}
+		return c.String(http.StatusOK, body)
+	}
+
+	for _, encoding := range []string{EncodingGzip, EncodingBrotli, EncodingZstd} {
+		suite.Run(encoding, func() {
+			store := &memoryStore{}
+			middleware := CacheWithConfig(CacheConfig{
+				Store:        store,
+				Encoder:      suite.enc,
+				CacheKey:     suite.testCacheKey,
+				GenerateETag: true,
+				Compression:  encoding,
+			})
+			request := func(header http.Header) *httptest.ResponseRecorder {
+				c, rec := createEchoContext(suite.e, "/")
+				maps.Copy(c.Request().Header, header)
+				suite.NoError(middleware(handler)(c))
+				return rec
+			}
+
+			rec := request(nil)
+			suite.Equal(body, rec.Body.String())
+
+			b, _ := store.Get("key")
+			var resp Response
+			suite.NoError(suite.enc.Unmarshal(b, &resp))
+			suite.Equal(encoding, resp.Encoding)
+			suite.Less(len(resp.Body), len(body))
+			etag := resp.Headers.Get("ETag")
+			suite.Equal(generateETag([]byte(body)), etag)
+
+			// served compressed
+			rec = request(http.Header{echo.HeaderAcceptEncoding: {"gzip, br, zstd"}})
+			suite.Equal(encoding, rec.Header().Get(echo.HeaderContentEncoding))
+			suite.Equal(encodedETag(etag, encoding), rec.Header().Get("ETag"))
+			suite.Equal(echo.HeaderAcceptEncoding, rec.Header().Get(echo.HeaderVary))
+			decoded, err := decompressBody(encoding, rec.Body.Bytes())
+			suite.NoError(err)
+			suite.Equal(body, string(decoded))
+
+			rec = request(http.Header{
+				echo.HeaderAcceptEncoding: {encoding},
+				"If-None-Match":           {encodedETag(etag, encoding)},
+			})
+			suite.Equal(http.StatusNotModified, rec.Code)
+
+			// decompressed for the others
+			rec = request(http.Header{echo.HeaderAcceptEncoding: {encoding + ";q=0, *"}})
+			suite.Empty(rec.Header().Get(echo.HeaderContentEncoding))
+			suite.Equal(etag, rec.Header().Get("ETag"))
+			suite.Equal(echo.HeaderAcceptEncoding, rec.Header().Get(echo.HeaderVary))
+			suite.Equal(body, rec.Body.String())
+		})
+	}
+
+	suite.Run("Small body is stored as is", func() {
+		store := &memoryStore{}
+		middleware := CacheWithConfig(CacheConfig{
+			Store:       store,
+			Encoder:     suite.enc,
+			CacheKey:    suite.testCacheKey,
+			Compression: EncodingGzip,
+		})
+		c, _ := createEchoContext(suite.e, "/small")
+		suite.NoError(middleware(handler)(c))
+
+		b, _ := store.Get("key")
+		var resp Response
+		suite.NoError(suite.enc.Unmarshal(b, &resp))
+		suite.Empty(resp.Encoding)
+		suite.Equal("OK", string(resp.Body))
+	})
+
+	suite.Run("Unsupported compression", func() {
+		suite.Panics(func() {
+			CacheWithConfig(CacheConfig{Compression: "deflate"})
+		})
+	})
+}
+
+func (suite *middlewareTestSuite) TestMaxCacheableSize() {
+	calls := 0
+	handler := func(c echo.Context) error {
+		calls++
+		return c.String(http.StatusOK, strings.Repeat("a", 2048))
+	}
+	store := &memoryStore{}
+	middleware := CacheWithConfig(CacheConfig{
+		Store:            store,
+		MaxCacheableSize: SizeKB,
+	})
+
+	for range 2 {
+		c, rec := createEchoContext(suite.e, "/")
+		suite.NoError(middleware(handler)(c))
+		suite.Equal(2048, rec.Body.Len())
+	}
+	suite.Equal(2, calls)
+	v, _ := store.Get(DefaultCacheKey(DefaultCachePrefix, httptest.NewRequest(http.MethodGet, "/", nil)))
+	suite.Nil(v)
+
+	suite.Run("Stale if error", func() {
+		store := &memoryStore{}
+		middleware := CacheWithConfig(CacheConfig{
+			Store:            store,
+			Encoder:          suite.enc,
+			CacheKey:         suite.testCacheKey,
+			StaleIfError:     time.Minute,
+			MaxCacheableSize: SizeKB,
+		})
+		resp := NewResponse(http.StatusOK, nil, []byte("STALE"))
+		resp.ExpiresAt = time.Now().Add(-time.Second).UnixMilli()
+		resp.StaleIfErrorUntil = time.Now().Add(time.Minute).UnixMilli()
+		b, err := suite.enc.Marshal(resp)
+		suite.NoError(err)
+		suite.NoError(store.Set("key", b, 0))
+
+		c, rec := createEchoContext(suite.e, "/")
+		suite.NoError(middleware(func(c echo.Context) error {
+			return c.String(http.StatusBadGateway, strings.Repeat("e", 2048))
+		})(c))
+		suite.Equal(http.StatusOK, rec.Code)
+		suite.Equal("STALE", rec.Body.String())
+
+		// passed through once larger than the limit
+		c, rec = createEchoContext(suite.e, "/")
+		suite.NoError(middleware(handler)(c))
+		suite.Equal(http.StatusOK, rec.Code)
+		suite.Equal(strings.Repeat("a", 2048), rec.Body.String())
+		v, _ := store.Get("key")
+		suite.Equal(b, v)
+	})
+}
+
+// streamStore keeps the values written in chunks apart
+type streamStore struct {
+	memoryStore
+	streams sync.Map
+	ttls    sync.Map
+}
+
+func (s *streamStore) SetStream(key string, r io.Reader, ttl time.Duration) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.streams.Store(key, b)
+	s.ttls.Store(key, ttl)
+	return nil
+}
+
+func (s *streamStore) GetStream(key string) (io.ReadCloser, error) {
+	v, ok := s.streams.Load(key)
+	if !ok {
+		return nil, nil
+	}
+	return nopSeekCloser{bytes.NewReader(v.([]byte))}, nil
+}
+
+type nopSeekCloser struct {
+	*bytes.Reader
+}
+
+func (nopSeekCloser) Close() error {
+	return nil
+}
+
+func (suite *middlewareTestSuite) TestStreamStore() {
+	calls := 0
+	chunk := strings.Repeat("a", 1024)
+	handler := func(c echo.Context) error {
+		calls++
+		c.Response().WriteHeader(http.StatusOK)
+		for range 4 {
+			c.Response().Write([]byte(chunk))
+		}
+		return nil
+	}
+	store := &streamStore{}
+	middleware := CacheWithConfig(CacheConfig{
+		Store:           store,
+		Encoder:         suite.enc,
+		CacheKey:        suite.testCacheKey,
+		StreamThreshold: 2 * SizeKB,
+		GenerateETag:    true,
+	})
+	request := func() *httptest.ResponseRecorder {
+		c, rec := createEchoContext(suite.e, "/")
+		suite.NoError(middleware(handler)(c))
+		suite.Equal(strings.Repeat(chunk, 4), rec.Body.String())
+		return rec
+	}
+
+	request()
+	v, _ := store.Get("key")
+	var resp Response
+	suite.NoError(suite.enc.Unmarshal(v, &resp))
+	suite.Empty(resp.Body)
+	suite.NotEmpty(resp.BodyKey)
+	suite.Equal(generateETag([]byte(strings.Repeat(chunk, 4))), resp.Headers.Get("ETag"))
+	// the response never expires, its body does
+	ttl, _ := store.ttls.Load(resp.BodyKey)
+	suite.Equal(DefaultStreamBodyTTL, ttl)
+
+	rec := request()
+	suite.Equal(1, calls)
+	suite.Equal(resp.Headers.Get("ETag"), rec.Header().Get("ETag"))
+
+	suite.Run("Body is gone", func() {
+		store.streams.Delete(resp.BodyKey)
+		request()
+		suite.Equal(2, calls)
+	})
+
+	suite.Run("Range from seekable body", func() {
+		c, rec := createEchoContext(suite.e, "/")
+		c.Request().Header.Set("Range", "bytes=1020-1030")
+		suite.NoError(middleware(handler)(c))
+		suite.Equal(http.StatusPartialContent, rec.Code)
+		suite.Equal("bytes 1020-1030/4096", rec.Header().Get("Content-Range"))
+		suite.Equal(chunk[:11], rec.Body.String())
+	})
+
+	suite.Run("Small body is not streamed", func() {
+		store := &streamStore{}
+		middleware := CacheWithConfig(CacheConfig{
+			Store:           store,
+			Encoder:         suite.enc,
+			CacheKey:        suite.testCacheKey,
+			StreamThreshold: 8 * SizeKB,
+		})
+		c, _ := createEchoContext(suite.e, "/")
+		suite.NoError(middleware(handler)(c))
+
+		v, _ := store.Get("key")
+		var resp Response
+		suite.NoError(suite.enc.Unmarshal(v, &resp))
+		suite.Len(resp.Body, 4096)
+		suite.Empty(resp.BodyKey)
+	})
+}
+
+// prefixSizeStore reports size as the size of the default cache prefix
+type prefixSizeStore struct {
+	memoryStore
+	size int64
+	err  error
+}
+
+func (s *prefixSizeStore) SizeBytesPrefix(prefix string) (int64, error) {
+	if prefix != DefaultCachePrefix+"-" {
+		return 0, errors.New("unexpected prefix " + prefix)
+	}
+	return s.size, s.err
+}
+
+// errorMetrics counts the errors reported
+type errorMetrics struct {
+	dummyMetrics
+	errors atomic.Int32
+}
+
+func (m *errorMetrics) CacheError() {
+	m.errors.Add(1)
+}
+
+// sizeMetrics records the sizes reported
+type sizeMetrics struct {
+	dummyMetrics
+	mu    sync.Mutex
+	sizes []float64
+}
+
+func (m *sizeMetrics) CacheSize(size float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sizes = append(m.sizes, size)
+}
+
+func (m *sizeMetrics) reported() []float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.sizes)
+}
+
+func (suite *middlewareTestSuite) TestMetricsCacheSize() {
+	handler := func(c echo.Context) error {
+		return c.String(http.StatusOK, "OK")
+	}
+	request := func(middleware echo.MiddlewareFunc, url string) {
+		c, _ := createEchoContext(suite.e, url)
+		suite.NoError(middleware(handler)(c))
+	}
+
+	suite.Run("Store inspector", func() {
+		store := memorystore.New(1024)
+		metrics := &sizeMetrics{}
+		middleware := CacheWithConfig(CacheConfig{
+			Store:             store,
+			Encoder:           suite.enc,
+			Metrics:           metrics,
+			StoreSizeInterval: time.Minute,
+		})
+		request(middleware, "/a")
+		request(middleware, "/b")
+
+		size, _ := store.(*memorystore.MemoryStore).SizeBytes()
+		suite.Eventually(func() bool {
+			return len(metrics.reported()) > 0
+		}, time.Second, time.Millisecond)
+		// read once per interval
+		reported := metrics.reported()
+		suite.Len(reported, 1)
+		suite.LessOrEqual(reported[0], float64(size))
+		suite.Greater(reported[0], float64(0))
+	})
+
+	suite.Run("Size of the cache prefix", func() {
+		metrics := &sizeMetrics{}
+		middleware := CacheWithConfig(CacheConfig{
+			Store:             &prefixSizeStore{size: 42},
+			Encoder:           suite.enc,
+			Metrics:           metrics,
+			StoreSizeInterval: time.Minute,
+		})
+		request(middleware, "/a")
+
+		suite.Eventually(func() bool {
+			return len(metrics.reported()) > 0
+		}, time.Second, time.Millisecond)
+		suite.Equal([]float64{42}, metrics.reported())
+	})
+
+	suite.Run("Size not supported", func() {
+		metrics := &sizeMetrics{}
+		middleware := CacheWithConfig(CacheConfig{
+			Store:             &prefixSizeStore{err: store.ErrNotSupported},
+			Encoder:           suite.enc,
+			Metrics:           metrics,
+			StoreSizeInterval: time.Minute,
+		})
+
+		// the size of the saved responses once the store can't tell
+		page := 0
+		suite.Eventually(func() bool {
+			page++
+			request(middleware, fmt.Sprintf("/posts?page=%d", page))
+			return len(metrics.reported()) > 0
+		}, time.Second, time.Millisecond)
+		suite.Greater(metrics.reported()[0], float64(0))
+	})
+
+	suite.Run("Size of the saved responses", func() {
+		store := &memoryStore{}
+		metrics := &sizeMetrics{}
+		middleware := CacheWithConfig(CacheConfig{Store: store, Encoder: suite.enc, Metrics: metrics})
+		request(middleware, "/a")
+		request(middleware, "/b")
+
+		v, _ := store.Get(DefaultCacheKey(DefaultCachePrefix, httptest.NewRequest(http.MethodGet, "/a", nil)))
+		suite.Equal([]float64{float64(len(v)), float64(len(v))}, metrics.reported())
+	})
+
+	suite.Run("Store size is opt-in", func() {
+		metrics := &sizeMetrics{}
+		middleware := CacheWithConfig(CacheConfig{Store: &prefixSizeStore{size: 42}, Encoder: suite.enc, Metrics: metrics})
+		request(middleware, "/a")
+
+		reported := metrics.reported()
+		suite.Len(reported, 1)
+		suite.NotEqual(float64(42), reported[0])
+	})
+}
+
 func TestCacheMiddleware(t *testing.T) {
 	suite.Run(t, new(middlewareTestSuite))
 }
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/sdvcrx/echo-cache/store"
+)
+
+var (
+	ErrStoreRequired     = errors.New("echo-cache: config.Store is required")
+	ErrClearNotSupported = errors.New("echo-cache: store doesn't support clear")
+)
+
+// Purge deletes the cached response of the request `method target`, target is
+// the request URI as received by the server, e.g. `/posts?page=1`.
+// config must use the same Store, CachePrefix and CacheKey as the middleware.
+func (config CacheConfig) Purge(method, target string) error {
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return err
+	}
+	return config.PurgeRequest(req)
+}
+
+// PurgeRequest deletes the cached response of req,
+// all the variants of the response are purged too.
+func (config CacheConfig) PurgeRequest(req *http.Request) error {
+	if config.Store == nil {
+		return ErrStoreRequired
+	}
+	if config.CachePrefix == "" {
+		config.CachePrefix = DefaultCachePrefix
+	}
+	if config.CacheKey == nil {
+		config.CacheKey = DefaultCacheKey
+	}
+	// variants older than a new variant index are ignored,
+	// so deleting the index is enough to purge them
+	return config.Store.Delete(config.CacheKey(config.CachePrefix, req))
+}
+
+// Clear deletes all the cached responses of config, the keys starting with
+// `CachePrefix + "-"` like the keys of DefaultCacheKey. The responses of
+// other prefixes sharing the store are kept.
+func (config CacheConfig) Clear() error {
+	if config.Store == nil {
+		return ErrStoreRequired
+	}
+	if config.CachePrefix == "" {
+		config.CachePrefix = DefaultCachePrefix
+	}
+	cl, ok := config.Store.(store.Clearer)
+	if !ok {
+		return ErrClearNotSupported
+	}
+	err := cl.Clear(config.CachePrefix + "-")
+	if errors.Is(err, store.ErrNotSupported) {
+		// a wrapper of a store without clear
+		return ErrClearNotSupported
+	}
+	return err
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"io"
+	"maps"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// rangeRequest reports whether the request asks for a part of resp
+func rangeRequest(req *http.Request, resp *Response) bool {
+	return req.Header.Get("Range") != "" &&
+		resp.StatusCode == http.StatusOK
+}
+
+// serveRange answers a Range request with the requested parts of the
+// cached response: 206 with `Content-Range` for a single range,
+// `multipart/byteranges` for several and 416 when unsatisfiable.
+// The full response is sent when `If-Range` doesn't match
+// its `ETag` or `Last-Modified`.
+func serveRange(c echo.Context, resp *Response, body io.ReadSeeker) {
+	maps.Copy(c.Response().Header(), resp.Headers)
+	modtime, _ := http.ParseTime(resp.Headers.Get(echo.HeaderLastModified))
+	http.ServeContent(c.Response(), c.Request(), "", modtime, body)
+}
+
+// serveStreamRange answers a Range request from a streamed body, the ranges
+// are cut when the stream store returns a seekable reader and the full
+// response is sent otherwise. It returns false when the body is gone.
+func (m *cacheMiddleware) serveStreamRange(c echo.Context, resp *Response) bool {
+	body, ok := m.openBody(c, resp)
+	if !ok {
+		return false
+	}
+	defer body.Close()
+
+	if rs, ok := body.(io.ReadSeeker); ok {
+		serveRange(c, resp, rs)
+		return true
+	}
+	writeStream(c, resp, body)
+	return true
+}
@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func (r *Response) stale(now time.Time) bool {
+	return r.ExpiresAt > 0 && now.UnixMilli() >= r.ExpiresAt
+}
+
+// revalidatable reports whether the stale response can still be
+// served while it is refreshed in background
+func (r *Response) revalidatable(now time.Time) bool {
+	return now.UnixMilli() < r.StaleUntil
+}
+
+// usableIfError reports whether the stale response can still be
+// served when the handler fails
+func (r *Response) usableIfError(now time.Time) bool {
+	return now.UnixMilli() < r.StaleIfErrorUntil
+}
+
+// responseRecorder captures a response without sending it,
+// the body goes to `body`
+type responseRecorder struct {
+	header     http.Header
+	statusCode int
+	body       io.Writer
+}
+
+func newResponseRecorder(header http.Header, body io.Writer) *responseRecorder {
+	return &responseRecorder{
+		header:     header,
+		statusCode: http.StatusOK,
+		body:       body,
+	}
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	r.statusCode = code
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+// Flush does nothing, the response is sent once the handler is done
+func (r *responseRecorder) Flush() {}
+
+// heldBody holds back the body of a response recorded by rec until it exceeds
+// limit, the response is then passed through to w. The body of a 5xx response
+// is dropped instead, the stale response replaces it anyway.
+type heldBody struct {
+	buf   bytes.Buffer
+	limit int64
+	rec   *responseRecorder
+	w     http.ResponseWriter
+	// passed is set once the response goes to w
+	passed  bool
+	dropped bool
+}
+
+func (h *heldBody) Write(b []byte) (int, error) {
+	if h.passed {
+		return h.w.Write(b)
+	}
+	if h.dropped {
+		return len(b), nil
+	}
+	if h.limit <= 0 || int64(h.buf.Len()+len(b)) <= h.limit {
+		return h.buf.Write(b)
+	}
+
+	held := h.buf.Bytes()
+	h.buf = bytes.Buffer{}
+	if h.rec.statusCode >= http.StatusInternalServerError {
+		h.dropped = true
+		return len(b), nil
+	}
+	h.passed = true
+	maps.Copy(h.w.Header(), h.rec.header)
+	h.w.WriteHeader(h.rec.statusCode)
+	if _, err := h.w.Write(held); err != nil {
+		return 0, err
+	}
+	return h.w.Write(b)
+}
+
+// Request headers that would make the handler answer with a partial response
+var revalidateDropHeaders = []string{
+	echo.HeaderIfModifiedSince,
+	"If-None-Match",
+	"If-Range",
+	"Range",
+}
+
+// refresh replays the request of c against the handler chain in background
+// and saves the new response, only one refresh runs per key at a time.
+func (m *cacheMiddleware) refresh(c echo.Context, next echo.HandlerFunc, key string, index *Response) {
+	if _, loaded := m.refreshing.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+
+	// c is reused by echo once the request is done,
+	// copy everything needed before leaving
+	req := c.Request().Clone(context.Background())
+	for _, name := range revalidateDropHeaders {
+		req.Header.Del(name)
+	}
+	rec := newResponseRecorder(http.Header{}, nil)
+	rc := c.Echo().NewContext(req, rec)
+	rc.SetPath(c.Path())
+	rc.SetParamNames(c.ParamNames()...)
+	rc.SetParamValues(c.ParamValues()...)
+	capture := m.newCapture(rc, key)
+	rec.body = capture
+
+	go func() {
+		defer m.refreshing.Delete(key)
+		defer capture.discard()
+		defer func() {
+			if r := recover(); r != nil {
+				rc.Logger().Errorf("[echo-cache] Failed to refresh cache, key=%s err=%v", key, r)
+			}
+		}()
+
+		if err := next(rc); err != nil {
+			rc.Error(err)
+		}
+		m.save(rc, key, index, capture)
+	}()
+}
+
+// serveStaleIfError runs the handler with its response held back, the stale
+// response is served instead when the handler fails with an error or a 5xx
+// status, otherwise the new response is sent and saved. The handler context
+// is cancelled after `StaleIfErrorTimeout`.
+func (m *cacheMiddleware) serveStaleIfError(c echo.Context, next echo.HandlerFunc, key string, index *Response, stale *Response, status *cacheStatus) {
+	res := c.Response()
+	writer := res.Writer
+	// the body is held in memory up to `MaxCacheableSize` like a captured body
+	body := &heldBody{limit: m.config.MaxCacheableSize, w: writer}
+	rec := newResponseRecorder(writer.Header().Clone(), body)
+	body.rec = rec
+	res.Writer = rec
+
+	req := c.Request()
+	if timeout := m.config.StaleIfErrorTimeout; timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		defer cancel()
+		c.SetRequest(req.WithContext(ctx))
+	}
+	if err := next(c); err != nil {
+		c.Error(err)
+	}
+
+	// the store operations are not bounded by the handler timeout
+	c.SetRequest(req)
+	res.Writer = writer
+	if body.passed {
+		// too large to be cached, it was sent as is
+		return
+	}
+	// send the response again through the original writer
+	res.Committed = false
+	res.Size = 0
+
+	if rec.statusCode >= http.StatusInternalServerError {
+		c.Logger().Warnf("[echo-cache] Serve stale response, key=%s status=%d", key, rec.statusCode)
+		res.Header().Set("Warning", `111 - "Revalidation Failed"`)
+		status.hit(stale, time.Now())
+		status.fwd = "stale"
+		status.fwdStatus = rec.statusCode
+		if m.write(c, stale) {
+			return
+		}
+		// the stale body is gone, send the failure
+		res.Header().Del("Warning")
+		*status = cacheStatus{result: CacheMiss, key: key, fwd: "stale"}
+	}
+
+	writeResponse(c, &Response{
+		StatusCode: rec.statusCode,
+		Headers:    rec.header,
+		Body:       body.buf.Bytes(),
+	})
+	if rec.statusCode >= http.StatusInternalServerError {
+		return
+	}
+	capture := m.newCapture(c, key)
+	capture.Write(body.buf.Bytes())
+	m.save(c, key, index, capture)
+}
@@ -1,6 +1,7 @@
 package boltstore
 
 import (
+	"bytes"
 	"log"
 	"time"
 
@@ -16,9 +17,15 @@ type BoltStore struct {
 	db     *bolt.DB
 	ticker *time.Ticker
 	bucket []byte
+	// Bucket of the tag index, keys are `tag + "\x00" + key`
+	tagBucket []byte
 }
 
 var _ store.Store = (*BoltStore)(nil)
+var _ store.TagStore = (*BoltStore)(nil)
+var _ store.Inspector = (*BoltStore)(nil)
+var _ store.Clearer = (*BoltStore)(nil)
+var _ store.BatchStore = (*BoltStore)(nil)
 
 type expirableMessage struct {
 	Value     []byte
@@ -36,8 +43,12 @@ func New(ctx context.Context, path string) *BoltStore {
 	}
 
 	bucket := []byte("cache")
+	tagBucket := []byte("tags")
 	err = db.Update(func(t *bolt.Tx) error {
-		_, err := t.CreateBucketIfNotExists(bucket)
+		if _, err := t.CreateBucketIfNotExists(bucket); err != nil {
+			return err
+		}
+		_, err := t.CreateBucketIfNotExists(tagBucket)
 		return err
 	})
 	if err != nil {
@@ -45,9 +56,10 @@ func New(ctx context.Context, path string) *BoltStore {
 	}
 
 	ba := &BoltStore{
-		ctx:    ctx,
-		bucket: bucket,
-		db:     db,
+		ctx:       ctx,
+		bucket:    bucket,
+		tagBucket: tagBucket,
+		db:        db,
 	}
 	ba.startCleanupTicker()
 	return ba
@@ -79,22 +91,38 @@ func (ba *BoltStore) cleanupExpired() error {
 		return err
 	}
 	defer tx.Rollback()
-	b := tx.Bucket(ba.bucket)
-	err = b.ForEach(func(k, v []byte) error {
+	for _, name := range [][]byte{ba.bucket, ba.tagBucket} {
+		if err := cleanupBucket(tx.Bucket(name)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func cleanupBucket(b *bolt.Bucket) error {
+	// collect first, deleting while iterating a cursor skips keys
+	var expired [][]byte
+	err := b.ForEach(func(k, v []byte) error {
 		var msg expirableMessage
 		err := msgpack.Unmarshal(v, &msg)
 		if err != nil {
 			return err
 		}
 		if msg.Expired() {
-			return b.Delete(k)
+			expired = append(expired, bytes.Clone(k))
 		}
 		return nil
 	})
 	if err != nil {
 		return err
 	}
-	return tx.Commit()
+
+	for _, k := range expired {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (ba *BoltStore) Get(key string) ([]byte, error) {
@@ -136,3 +164,189 @@ func (ba *BoltStore) Set(key string, val []byte, ttl time.Duration) error {
 		return b.Put([]byte(key), msgb)
 	})
 }
+
+func (ba *BoltStore) Delete(key string) error {
+	return ba.db.Batch(func(t *bolt.Tx) error {
+		return t.Bucket(ba.bucket).Delete([]byte(key))
+	})
+}
+
+// GetMulti reads keys in one transaction
+func (ba *BoltStore) GetMulti(keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	err := ba.db.View(func(t *bolt.Tx) error {
+		b := t.Bucket(ba.bucket)
+		for _, key := range keys {
+			val := b.Get([]byte(key))
+			if val == nil {
+				continue
+			}
+			var msg expirableMessage
+			if err := msgpack.Unmarshal(val, &msg); err != nil {
+				return err
+			}
+			if !msg.Expired() {
+				values[key] = msg.Value
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// SetMulti writes items in one transaction
+func (ba *BoltStore) SetMulti(items map[string][]byte, ttl time.Duration) error {
+	expiredAt := time.Now().Add(ttl)
+	return ba.db.Batch(func(t *bolt.Tx) error {
+		b := t.Bucket(ba.bucket)
+		for key, val := range items {
+			msgb, err := msgpack.Marshal(expirableMessage{Value: val, ExpiredAt: expiredAt})
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(key), msgb); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteMulti deletes keys in one transaction
+func (ba *BoltStore) DeleteMulti(keys []string) error {
+	return ba.db.Batch(func(t *bolt.Tx) error {
+		b := t.Bucket(ba.bucket)
+		for _, key := range keys {
+			if err := b.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func tagPrefix(tag string) []byte {
+	return append([]byte(tag), 0)
+}
+
+func (ba *BoltStore) Tag(key string, tags []string, ttl time.Duration) error {
+	msgb, err := msgpack.Marshal(expirableMessage{
+		ExpiredAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return err
+	}
+	return ba.db.Batch(func(t *bolt.Tx) error {
+		b := t.Bucket(ba.tagBucket)
+		for _, tag := range tags {
+			if err := b.Put(append(tagPrefix(tag), key...), msgb); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (ba *BoltStore) PurgeTag(tag string) error {
+	prefix := tagPrefix(tag)
+	return ba.db.Update(func(t *bolt.Tx) error {
+		b := t.Bucket(ba.bucket)
+		tb := t.Bucket(ba.tagBucket)
+
+		// collect first, deleting while iterating a cursor skips keys
+		var tagKeys [][]byte
+		c := tb.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			tagKeys = append(tagKeys, bytes.Clone(k))
+		}
+
+		for _, k := range tagKeys {
+			if err := b.Delete(k[len(prefix):]); err != nil {
+				return err
+			}
+			if err := tb.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Len returns the number of keys, counting the expired keys
+// until the cleanup ticker removes them
+func (ba *BoltStore) Len() (int64, error) {
+	var n int64
+	err := ba.db.View(func(t *bolt.Tx) error {
+		n = int64(t.Bucket(ba.bucket).Stats().KeyN)
+		return nil
+	})
+	return n, err
+}
+
+// SizeBytes returns the size of the keys and the encoded values
+func (ba *BoltStore) SizeBytes() (int64, error) {
+	var size int64
+	err := ba.db.View(func(t *bolt.Tx) error {
+		return t.Bucket(ba.bucket).ForEach(func(k, v []byte) error {
+			size += int64(len(k) + len(v))
+			return nil
+		})
+	})
+	return size, err
+}
+
+// Scan seeks the keys in order, expired keys are skipped
+func (ba *BoltStore) Scan(prefix string, cursor string, count int) ([]string, string, error) {
+	var keys []string
+	next := ""
+	err := ba.db.View(func(t *bolt.Tx) error {
+		c := t.Bucket(ba.bucket).Cursor()
+		start := []byte(prefix)
+		if cursor > prefix {
+			start = []byte(cursor)
+		}
+		for k, v := c.Seek(start); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Next() {
+			if string(k) == cursor {
+				continue
+			}
+			if count > 0 && len(keys) == count {
+				next = keys[len(keys)-1]
+				return nil
+			}
+			var msg expirableMessage
+			if err := msgpack.Unmarshal(v, &msg); err != nil {
+				return err
+			}
+			if !msg.Expired() {
+				keys = append(keys, string(k))
+			}
+		}
+		return nil
+	})
+	return keys, next, err
+}
+
+// Clear deletes the keys starting with prefix in one transaction,
+// their tag index entries expire with them
+func (ba *BoltStore) Clear(prefix string) error {
+	return ba.db.Update(func(t *bolt.Tx) error {
+		b := t.Bucket(ba.bucket)
+
+		// collect first, deleting while iterating a cursor skips keys
+		var keys [][]byte
+		c := b.Cursor()
+		for k, _ := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, _ = c.Next() {
+			keys = append(keys, bytes.Clone(k))
+		}
+
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
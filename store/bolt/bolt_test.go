@@ -2,6 +2,7 @@ package boltstore
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -27,6 +28,9 @@ func TestBoltStore(t *testing.T) {
 	t.Run("cleanup", func(t *testing.T) {
 		// add expired responses
 		assert.NoError(t, c.Set(key, valByte, -1*time.Minute))
+		for i := range 100 {
+			assert.NoError(t, c.Set(fmt.Sprintf("expired-%d", i), valByte, -1*time.Minute))
+		}
 
 		// manual trigger cleanup
 		assert.NoError(t, c.cleanupExpired())
@@ -54,6 +58,16 @@ func TestBoltStore(t *testing.T) {
 		assert.Equal(t, valByte, resp)
 	})
 
+	t.Run("Delete", func(t *testing.T) {
+		key := "deleted"
+		assert.NoError(t, c.Set(key, valByte, time.Minute))
+		assert.NoError(t, c.Delete(key))
+
+		resp, err := c.Get(key)
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+	})
+
 	t.Run("Get nil", func(t *testing.T) {
 		resp, err := c.Get("key-dont-exist")
 		assert.NoError(t, err)
@@ -74,3 +88,103 @@ func TestBoltStore(t *testing.T) {
 		assert.Nil(t, res)
 	})
 }
+
+func TestBoltStoreTags(t *testing.T) {
+	c := New(context.Background(), t.TempDir()+"/bolt")
+	c.ticker.Stop()
+	body := []byte("OK")
+
+	t.Run("PurgeTag", func(t *testing.T) {
+		for _, key := range []string{"a", "b", "c"} {
+			assert.NoError(t, c.Set(key, body, time.Minute))
+		}
+		assert.NoError(t, c.Tag("a", []string{"product:1", "product:2"}, time.Minute))
+		assert.NoError(t, c.Tag("b", []string{"product:1"}, time.Minute))
+		// shares the prefix of `product:1`
+		assert.NoError(t, c.Tag("c", []string{"product:10"}, time.Minute))
+
+		assert.NoError(t, c.PurgeTag("product:1"))
+		for key, expected := range map[string][]byte{"a": nil, "b": nil, "c": body} {
+			r, err := c.Get(key)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, r)
+		}
+
+		// purge a missing tag
+		assert.NoError(t, c.PurgeTag("product:3"))
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		assert.NoError(t, c.Tag("expired", []string{"tag"}, -1*time.Minute))
+		assert.NoError(t, c.cleanupExpired())
+
+		err := c.db.View(func(tx *bbolt.Tx) error {
+			assert.Nil(t, tx.Bucket(c.tagBucket).Get(append(tagPrefix("tag"), "expired"...)))
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+}
+
+func TestBoltStoreInspector(t *testing.T) {
+	c := New(context.Background(), t.TempDir()+"/bolt")
+	c.ticker.Stop()
+	for _, key := range []string{"a-1", "b-1", "b-2", "b-3"} {
+		assert.NoError(t, c.Set(key, []byte("OK"), time.Minute))
+	}
+	assert.NoError(t, c.Set("b-0", []byte("OK"), -time.Minute))
+
+	n, err := c.Len()
+	assert.NoError(t, err)
+	// the expired key is not cleaned up yet
+	assert.Equal(t, int64(5), n)
+
+	size, err := c.SizeBytes()
+	assert.NoError(t, err)
+	assert.Greater(t, size, int64(5*(3+2)))
+
+	keys, cursor, err := c.Scan("b-", "", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b-1", "b-2"}, keys)
+	keys, cursor, err = c.Scan("b-", cursor, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b-3"}, keys)
+	assert.Equal(t, "", cursor)
+}
+
+func TestBoltStoreClear(t *testing.T) {
+	c := New(context.Background(), t.TempDir()+"/bolt")
+	c.ticker.Stop()
+	for _, key := range []string{"app1-GET-/", "app1-GET-/posts", "app2-GET-/"} {
+		assert.NoError(t, c.Set(key, []byte("OK"), time.Minute))
+	}
+
+	assert.NoError(t, c.Clear("app1-"))
+	for key, expected := range map[string][]byte{"app1-GET-/": nil, "app1-GET-/posts": nil, "app2-GET-/": []byte("OK")} {
+		r, err := c.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, r)
+	}
+
+	assert.NoError(t, c.Clear(""))
+	n, err := c.Len()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+}
+
+func TestBoltStoreMulti(t *testing.T) {
+	c := New(context.Background(), t.TempDir()+"/bolt")
+	c.ticker.Stop()
+	items := map[string][]byte{"a": []byte("1"), "b": []byte("2")}
+
+	assert.NoError(t, c.SetMulti(items, time.Minute))
+	assert.NoError(t, c.Set("expired", []byte("3"), -time.Minute))
+	values, err := c.GetMulti([]string{"a", "b", "expired", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, items, values)
+
+	assert.NoError(t, c.DeleteMulti([]string{"a", "missing"}))
+	values, err = c.GetMulti([]string{"a", "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"b": []byte("2")}, values)
+}
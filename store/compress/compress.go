@@ -0,0 +1,222 @@
+package compressstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/sdvcrx/echo-cache/store"
+)
+
+type Codec byte
+
+const (
+	// Value stored as is
+	None Codec = iota
+	Zstd
+	// Snappy block format
+	Snappy
+)
+
+// Values written by the store start with this byte followed by the codec.
+// It is never used by msgpack nor valid UTF-8, so values saved without
+// the wrapper are read as is.
+const magic byte = 0xc1
+
+// Values smaller than this are not compressed by default
+const DefaultThreshold = 1024
+
+// EncodeAll and DecodeAll are safe for concurrent use
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+type CompressStoreOption struct {
+	// The wrapped store
+	Store store.Store
+	// Defaults to Zstd
+	Codec Codec
+	// Values smaller than this are stored uncompressed,
+	// defaults to DefaultThreshold
+	Threshold int
+}
+
+// CompressStore compresses the values before saving them to the wrapped store
+type CompressStore struct {
+	CompressStoreOption
+	inner store.ContextStore
+}
+
+func New(option CompressStoreOption) store.Store {
+	if option.Codec == None {
+		option.Codec = Zstd
+	}
+	if option.Threshold == 0 {
+		option.Threshold = DefaultThreshold
+	}
+	return &CompressStore{
+		CompressStoreOption: option,
+		inner:               store.WithContext(option.Store),
+	}
+}
+
+var _ store.Store = (*CompressStore)(nil)
+var _ store.ContextStore = (*CompressStore)(nil)
+var _ store.TagStore = (*CompressStore)(nil)
+var _ store.ContextTagStore = (*CompressStore)(nil)
+var _ store.Inspector = (*CompressStore)(nil)
+var _ store.PrefixSizer = (*CompressStore)(nil)
+var _ store.Clearer = (*CompressStore)(nil)
+var _ store.BatchStore = (*CompressStore)(nil)
+
+func (cs *CompressStore) encode(val []byte) []byte {
+	if len(val) >= cs.Threshold {
+		var compressed []byte
+		switch cs.Codec {
+		case Zstd:
+			compressed = zstdEncoder.EncodeAll(val, []byte{magic, byte(Zstd)})
+		case Snappy:
+			compressed = append([]byte{magic, byte(Snappy)}, s2.EncodeSnappy(nil, val)...)
+		}
+		if compressed != nil && len(compressed) < len(val) {
+			return compressed
+		}
+	}
+	// keep values looking like the header readable
+	if len(val) > 0 && val[0] == magic {
+		return append([]byte{magic, byte(None)}, val...)
+	}
+	return val
+}
+
+func decode(val []byte) ([]byte, error) {
+	if len(val) < 2 || val[0] != magic {
+		return val, nil
+	}
+	switch Codec(val[1]) {
+	case None:
+		return val[2:], nil
+	case Zstd:
+		return zstdDecoder.DecodeAll(val[2:], nil)
+	case Snappy:
+		return s2.Decode(nil, val[2:])
+	}
+	return nil, fmt.Errorf("compressstore: unknown codec %d", val[1])
+}
+
+func (cs *CompressStore) Get(key string) ([]byte, error) {
+	return cs.GetContext(context.Background(), key)
+}
+
+func (cs *CompressStore) GetContext(ctx context.Context, key string) ([]byte, error) {
+	val, err := cs.inner.GetContext(ctx, key)
+	if err != nil || val == nil {
+		return nil, err
+	}
+	return decode(val)
+}
+
+func (cs *CompressStore) Set(key string, val []byte, ttl time.Duration) error {
+	return cs.SetContext(context.Background(), key, val, ttl)
+}
+
+func (cs *CompressStore) SetContext(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	return cs.inner.SetContext(ctx, key, cs.encode(val), ttl)
+}
+
+func (cs *CompressStore) Delete(key string) error {
+	return cs.inner.Delete(key)
+}
+
+func (cs *CompressStore) DeleteContext(ctx context.Context, key string) error {
+	return cs.inner.DeleteContext(ctx, key)
+}
+
+func (cs *CompressStore) Tag(key string, tags []string, ttl time.Duration) error {
+	return cs.TagContext(context.Background(), key, tags, ttl)
+}
+
+func (cs *CompressStore) TagContext(ctx context.Context, key string, tags []string, ttl time.Duration) error {
+	ts, ok := cs.Store.(store.TagStore)
+	if !ok {
+		return store.ErrNotSupported
+	}
+	return store.TagContext(ctx, ts, key, tags, ttl)
+}
+
+func (cs *CompressStore) PurgeTag(tag string) error {
+	ts, ok := cs.Store.(store.TagStore)
+	if !ok {
+		return store.ErrNotSupported
+	}
+	return ts.PurgeTag(tag)
+}
+
+func (cs *CompressStore) Len() (int64, error) {
+	is, ok := cs.Store.(store.Inspector)
+	if !ok {
+		return 0, store.ErrNotSupported
+	}
+	return is.Len()
+}
+
+// SizeBytes reports the compressed size
+func (cs *CompressStore) SizeBytes() (int64, error) {
+	is, ok := cs.Store.(store.Inspector)
+	if !ok {
+		return 0, store.ErrNotSupported
+	}
+	return is.SizeBytes()
+}
+
+func (cs *CompressStore) SizeBytesPrefix(prefix string) (int64, error) {
+	ps, ok := cs.Store.(store.PrefixSizer)
+	if !ok {
+		return 0, store.ErrNotSupported
+	}
+	return ps.SizeBytesPrefix(prefix)
+}
+
+func (cs *CompressStore) Scan(prefix string, cursor string, count int) ([]string, string, error) {
+	is, ok := cs.Store.(store.Inspector)
+	if !ok {
+		return nil, "", store.ErrNotSupported
+	}
+	return is.Scan(prefix, cursor, count)
+}
+
+func (cs *CompressStore) Clear(prefix string) error {
+	cl, ok := cs.Store.(store.Clearer)
+	if !ok {
+		return store.ErrNotSupported
+	}
+	return cl.Clear(prefix)
+}
+
+func (cs *CompressStore) GetMulti(keys []string) (map[string][]byte, error) {
+	values, err := store.GetMulti(cs.Store, keys)
+	if err != nil {
+		return nil, err
+	}
+	for key, val := range values {
+		if values[key], err = decode(val); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func (cs *CompressStore) SetMulti(items map[string][]byte, ttl time.Duration) error {
+	encoded := make(map[string][]byte, len(items))
+	for key, val := range items {
+		encoded[key] = cs.encode(val)
+	}
+	return store.SetMulti(cs.Store, encoded, ttl)
+}
+
+func (cs *CompressStore) DeleteMulti(keys []string) error {
+	return store.DeleteMulti(cs.Store, keys)
+}
@@ -0,0 +1,164 @@
+package compressstore
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sdvcrx/echo-cache/store"
+	memorystore "github.com/sdvcrx/echo-cache/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressStore(t *testing.T) {
+	large := bytes.Repeat([]byte(`{"id":1,"name":"echo-cache"},`), 100)
+
+	for _, codec := range []Codec{Zstd, Snappy} {
+		inner := memorystore.New(1024)
+		cs := New(CompressStoreOption{Store: inner, Codec: codec})
+
+		t.Run("Compress large value", func(t *testing.T) {
+			assert.NoError(t, cs.Set("large", large, time.Minute))
+
+			raw, _ := inner.Get("large")
+			assert.Equal(t, []byte{magic, byte(codec)}, raw[:2])
+			assert.Less(t, len(raw), len(large)/5)
+
+			val, err := cs.Get("large")
+			assert.NoError(t, err)
+			assert.Equal(t, large, val)
+		})
+
+		t.Run("Small value is stored as is", func(t *testing.T) {
+			assert.NoError(t, cs.Set("small", []byte("OK"), time.Minute))
+
+			raw, _ := inner.Get("small")
+			assert.Equal(t, []byte("OK"), raw)
+
+			val, err := cs.Get("small")
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("OK"), val)
+		})
+	}
+
+	inner := memorystore.New(1024)
+	cs := New(CompressStoreOption{Store: inner})
+
+	t.Run("Read legacy value", func(t *testing.T) {
+		assert.NoError(t, inner.Set("legacy", large, time.Minute))
+
+		val, err := cs.Get("legacy")
+		assert.NoError(t, err)
+		assert.Equal(t, large, val)
+	})
+
+	t.Run("Value starting with the magic byte", func(t *testing.T) {
+		val := []byte{magic, byte(Zstd), 'O', 'K'}
+		assert.NoError(t, cs.Set("magic", val, time.Minute))
+
+		r, err := cs.Get("magic")
+		assert.NoError(t, err)
+		assert.Equal(t, val, r)
+	})
+
+	t.Run("Unknown codec", func(t *testing.T) {
+		assert.NoError(t, inner.Set("unknown", []byte{magic, 0xff, 'O', 'K'}, time.Minute))
+
+		_, err := cs.Get("unknown")
+		assert.Error(t, err)
+	})
+
+	t.Run("Inspector", func(t *testing.T) {
+		inner := memorystore.NewSharded(memorystore.ShardedStoreOption{MaxBytes: 1 << 20})
+		cs := New(CompressStoreOption{Store: inner})
+		assert.NoError(t, cs.Set("large", large, time.Minute))
+		assert.NoError(t, cs.Set("small", []byte("OK"), time.Minute))
+
+		is := cs.(store.Inspector)
+		n, err := is.Len()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+
+		// compressed values are counted
+		size, err := is.SizeBytes()
+		assert.NoError(t, err)
+		assert.Less(t, size, int64(len(large)))
+
+		keys, _, err := is.Scan("sm", "", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"small"}, keys)
+
+		_, err = New(CompressStoreOption{Store: struct{ store.Store }{inner}}).(store.Inspector).Len()
+		assert.ErrorIs(t, err, store.ErrNotSupported)
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		inner := memorystore.NewSharded(memorystore.ShardedStoreOption{MaxBytes: 1 << 20})
+		cs := New(CompressStoreOption{Store: inner})
+		assert.NoError(t, cs.Set("large", large, time.Minute))
+		assert.NoError(t, cs.Set("small", []byte("OK"), time.Minute))
+
+		assert.NoError(t, cs.(store.Clearer).Clear("sm"))
+		val, err := cs.Get("small")
+		assert.NoError(t, err)
+		assert.Nil(t, val)
+		val, err = cs.Get("large")
+		assert.NoError(t, err)
+		assert.Equal(t, large, val)
+
+		err = New(CompressStoreOption{Store: struct{ store.Store }{inner}}).(store.Clearer).Clear("")
+		assert.ErrorIs(t, err, store.ErrNotSupported)
+	})
+
+	t.Run("Multi", func(t *testing.T) {
+		bs := cs.(store.BatchStore)
+		items := map[string][]byte{"multi-large": large, "multi-small": []byte("OK")}
+		assert.NoError(t, bs.SetMulti(items, time.Minute))
+
+		raw, _ := inner.Get("multi-large")
+		assert.Equal(t, []byte{magic, byte(Zstd)}, raw[:2])
+
+		values, err := bs.GetMulti([]string{"multi-large", "multi-small", "missing"})
+		assert.NoError(t, err)
+		assert.Equal(t, items, values)
+
+		assert.NoError(t, bs.DeleteMulti([]string{"multi-large", "multi-small"}))
+		values, err = bs.GetMulti([]string{"multi-large", "multi-small"})
+		assert.NoError(t, err)
+		assert.Empty(t, values)
+	})
+
+	t.Run("Missing key", func(t *testing.T) {
+		val, err := cs.Get("missing")
+		assert.NoError(t, err)
+		assert.Nil(t, val)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, cs.Set("deleted", large, time.Minute))
+		assert.NoError(t, cs.Delete("deleted"))
+
+		val, err := cs.Get("deleted")
+		assert.NoError(t, err)
+		assert.Nil(t, val)
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		ts := cs.(store.TagStore)
+		assert.NoError(t, cs.Set("tagged", large, time.Minute))
+		assert.NoError(t, ts.Tag("tagged", []string{"tag"}, time.Minute))
+		assert.NoError(t, ts.PurgeTag("tag"))
+
+		val, _ := cs.Get("tagged")
+		assert.Nil(t, val)
+	})
+
+	t.Run("Context done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := cs.(store.ContextStore).GetContext(ctx, "large")
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
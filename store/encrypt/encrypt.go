@@ -0,0 +1,338 @@
+package encryptstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sdvcrx/echo-cache/store"
+)
+
+var (
+	ErrUnknownKey   = errors.New("encryptstore: value encrypted with an unknown key")
+	ErrInvalidValue = errors.New("encryptstore: invalid encrypted value")
+)
+
+// Format of the encrypted values:
+//
+//	version (1 byte) | key ID (4 bytes) | expires at (8 bytes, unix ms) | nonce | ciphertext
+//
+// The header and the cache key are authenticated with the ciphertext,
+// so a value can't be moved to another key.
+const (
+	version    byte = 1
+	headerSize      = 1 + 4 + 8
+)
+
+type Key struct {
+	ID uint32
+	// AES-128, AES-192 or AES-256 key
+	Secret []byte
+}
+
+type EncryptStoreOption struct {
+	// The wrapped store
+	Store store.Store
+	// Keys[0] encrypts new values, every key decrypts,
+	// keep the old keys until the values are rekeyed or expired
+	Keys []Key
+}
+
+// EncryptStore encrypts the values with AES-GCM before saving them to the wrapped store
+type EncryptStore struct {
+	EncryptStoreOption
+	inner   store.ContextStore
+	primary uint32
+	aeads   map[uint32]cipher.AEAD
+}
+
+// New panics when the keyring is empty or a key is invalid
+func New(option EncryptStoreOption) store.Store {
+	if len(option.Keys) == 0 {
+		panic("encryptstore: at least one key is required")
+	}
+	es := &EncryptStore{
+		EncryptStoreOption: option,
+		inner:              store.WithContext(option.Store),
+		primary:            option.Keys[0].ID,
+		aeads:              make(map[uint32]cipher.AEAD, len(option.Keys)),
+	}
+	for _, key := range option.Keys {
+		if _, ok := es.aeads[key.ID]; ok {
+			panic(fmt.Sprintf("encryptstore: duplicate key ID %d", key.ID))
+		}
+		block, err := aes.NewCipher(key.Secret)
+		if err != nil {
+			panic(fmt.Sprintf("encryptstore: invalid key %d: %s", key.ID, err))
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			panic(fmt.Sprintf("encryptstore: invalid key %d: %s", key.ID, err))
+		}
+		es.aeads[key.ID] = aead
+	}
+	return es
+}
+
+var _ store.Store = (*EncryptStore)(nil)
+var _ store.ContextStore = (*EncryptStore)(nil)
+var _ store.TagStore = (*EncryptStore)(nil)
+var _ store.ContextTagStore = (*EncryptStore)(nil)
+var _ store.Inspector = (*EncryptStore)(nil)
+var _ store.PrefixSizer = (*EncryptStore)(nil)
+var _ store.Clearer = (*EncryptStore)(nil)
+var _ store.BatchStore = (*EncryptStore)(nil)
+
+func (es *EncryptStore) encrypt(key string, val []byte, expiresAt int64) ([]byte, error) {
+	aead := es.aeads[es.primary]
+	out := make([]byte, headerSize, headerSize+aead.NonceSize()+len(val)+aead.Overhead())
+	out[0] = version
+	binary.BigEndian.PutUint32(out[1:5], es.primary)
+	binary.BigEndian.PutUint64(out[5:headerSize], uint64(expiresAt))
+
+	nonce := out[headerSize : headerSize+aead.NonceSize()]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out[:headerSize+len(nonce)], nonce, val, additionalData(out[:headerSize], key)), nil
+}
+
+// decrypt returns the plaintext of val, the ID of the key which
+// encrypted it and when it expires in unix milliseconds
+func (es *EncryptStore) decrypt(key string, val []byte) ([]byte, uint32, int64, error) {
+	if len(val) < headerSize || val[0] != version {
+		return nil, 0, 0, ErrInvalidValue
+	}
+	keyID := binary.BigEndian.Uint32(val[1:5])
+	expiresAt := int64(binary.BigEndian.Uint64(val[5:headerSize]))
+	aead, ok := es.aeads[keyID]
+	if !ok {
+		return nil, keyID, expiresAt, ErrUnknownKey
+	}
+
+	rest := val[headerSize:]
+	if len(rest) < aead.NonceSize()+aead.Overhead() {
+		return nil, keyID, expiresAt, ErrInvalidValue
+	}
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, additionalData(val[:headerSize], key))
+	if err != nil {
+		return nil, keyID, expiresAt, err
+	}
+	return plain, keyID, expiresAt, nil
+}
+
+func additionalData(header []byte, key string) []byte {
+	return append(append([]byte(nil), header...), key...)
+}
+
+func (es *EncryptStore) Get(key string) ([]byte, error) {
+	return es.GetContext(context.Background(), key)
+}
+
+func (es *EncryptStore) GetContext(ctx context.Context, key string) ([]byte, error) {
+	val, err := es.inner.GetContext(ctx, key)
+	if err != nil || val == nil {
+		return nil, err
+	}
+	plain, _, _, err := es.decrypt(key, val)
+	return plain, err
+}
+
+func (es *EncryptStore) Set(key string, val []byte, ttl time.Duration) error {
+	return es.SetContext(context.Background(), key, val, ttl)
+}
+
+func (es *EncryptStore) SetContext(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl).UnixMilli()
+	}
+	encrypted, err := es.encrypt(key, val, expiresAt)
+	if err != nil {
+		return err
+	}
+	return es.inner.SetContext(ctx, key, encrypted, ttl)
+}
+
+func (es *EncryptStore) Delete(key string) error {
+	return es.inner.Delete(key)
+}
+
+func (es *EncryptStore) DeleteContext(ctx context.Context, key string) error {
+	return es.inner.DeleteContext(ctx, key)
+}
+
+func (es *EncryptStore) Tag(key string, tags []string, ttl time.Duration) error {
+	return es.TagContext(context.Background(), key, tags, ttl)
+}
+
+func (es *EncryptStore) TagContext(ctx context.Context, key string, tags []string, ttl time.Duration) error {
+	ts, ok := es.Store.(store.TagStore)
+	if !ok {
+		return store.ErrNotSupported
+	}
+	return store.TagContext(ctx, ts, key, tags, ttl)
+}
+
+func (es *EncryptStore) PurgeTag(tag string) error {
+	ts, ok := es.Store.(store.TagStore)
+	if !ok {
+		return store.ErrNotSupported
+	}
+	return ts.PurgeTag(tag)
+}
+
+func (es *EncryptStore) Len() (int64, error) {
+	is, ok := es.Store.(store.Inspector)
+	if !ok {
+		return 0, store.ErrNotSupported
+	}
+	return is.Len()
+}
+
+// SizeBytes reports the encrypted size, the keys are not encrypted
+func (es *EncryptStore) SizeBytes() (int64, error) {
+	is, ok := es.Store.(store.Inspector)
+	if !ok {
+		return 0, store.ErrNotSupported
+	}
+	return is.SizeBytes()
+}
+
+func (es *EncryptStore) SizeBytesPrefix(prefix string) (int64, error) {
+	ps, ok := es.Store.(store.PrefixSizer)
+	if !ok {
+		return 0, store.ErrNotSupported
+	}
+	return ps.SizeBytesPrefix(prefix)
+}
+
+func (es *EncryptStore) Scan(prefix string, cursor string, count int) ([]string, string, error) {
+	is, ok := es.Store.(store.Inspector)
+	if !ok {
+		return nil, "", store.ErrNotSupported
+	}
+	return is.Scan(prefix, cursor, count)
+}
+
+func (es *EncryptStore) Clear(prefix string) error {
+	cl, ok := es.Store.(store.Clearer)
+	if !ok {
+		return store.ErrNotSupported
+	}
+	return cl.Clear(prefix)
+}
+
+func (es *EncryptStore) GetMulti(keys []string) (map[string][]byte, error) {
+	values, err := store.GetMulti(es.Store, keys)
+	if err != nil {
+		return nil, err
+	}
+	for key, val := range values {
+		if values[key], _, _, err = es.decrypt(key, val); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func (es *EncryptStore) SetMulti(items map[string][]byte, ttl time.Duration) error {
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl).UnixMilli()
+	}
+	encrypted := make(map[string][]byte, len(items))
+	for key, val := range items {
+		var err error
+		if encrypted[key], err = es.encrypt(key, val, expiresAt); err != nil {
+			return err
+		}
+	}
+	return store.SetMulti(es.Store, encrypted, ttl)
+}
+
+func (es *EncryptStore) DeleteMulti(keys []string) error {
+	return store.DeleteMulti(es.Store, keys)
+}
+
+// Rekey encrypts the values of keys, which were encrypted with an older key,
+// with Keys[0]. They keep their expiration. Values which can't be decrypted
+// are left alone and reported in the returned error. A value is not
+// rewritten when it changed meanwhile, though a value set between the check
+// and the write is still replaced.
+func (es *EncryptStore) Rekey(keys ...string) error {
+	var errs []error
+	for _, key := range keys {
+		if err := es.rekey(key); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Number of keys read per Scan by RekeyPrefix
+const rekeyScanCount = 100
+
+// RekeyPrefix rekeys the values of the keys starting with prefix like Rekey,
+// the wrapped store must implement store.Inspector to list them.
+func (es *EncryptStore) RekeyPrefix(prefix string) error {
+	is, ok := es.Store.(store.Inspector)
+	if !ok {
+		return store.ErrNotSupported
+	}
+	var errs []error
+	cursor := ""
+	for {
+		keys, next, err := is.Scan(prefix, cursor, rekeyScanCount)
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		if err := es.Rekey(keys...); err != nil {
+			errs = append(errs, err)
+		}
+		if next == "" {
+			return errors.Join(errs...)
+		}
+		cursor = next
+	}
+}
+
+func (es *EncryptStore) rekey(key string) error {
+	val, err := es.Store.Get(key)
+	if err != nil || val == nil {
+		return err
+	}
+	plain, keyID, expiresAt, err := es.decrypt(key, val)
+	if err != nil {
+		return err
+	}
+	if keyID == es.primary {
+		return nil
+	}
+
+	var ttl time.Duration
+	if expiresAt > 0 {
+		ttl = time.Until(time.UnixMilli(expiresAt))
+		if ttl <= 0 {
+			return nil
+		}
+	}
+	encrypted, err := es.encrypt(key, plain, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	// don't replace a value set meanwhile with the older one
+	current, err := es.Store.Get(key)
+	if err != nil || !bytes.Equal(current, val) {
+		return err
+	}
+	return es.Store.Set(key, encrypted, ttl)
+}
@@ -0,0 +1,214 @@
+package encryptstore
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sdvcrx/echo-cache/store"
+	memorystore "github.com/sdvcrx/echo-cache/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+// ttlStore records the ttl of the values
+type ttlStore struct {
+	data map[string][]byte
+	ttls map[string]time.Duration
+	// called after each Get
+	afterGet func(key string)
+}
+
+func newTTLStore() *ttlStore {
+	return &ttlStore{data: map[string][]byte{}, ttls: map[string]time.Duration{}}
+}
+
+func (s *ttlStore) Get(key string) ([]byte, error) {
+	val := s.data[key]
+	if s.afterGet != nil {
+		s.afterGet(key)
+	}
+	return val, nil
+}
+
+func (s *ttlStore) Set(key string, val []byte, ttl time.Duration) error {
+	s.data[key] = val
+	s.ttls[key] = ttl
+	return nil
+}
+
+func (s *ttlStore) Delete(key string) error {
+	delete(s.data, key)
+	return nil
+}
+
+var (
+	oldKey = Key{ID: 1, Secret: bytes.Repeat([]byte{1}, 32)}
+	newKey = Key{ID: 2, Secret: bytes.Repeat([]byte{2}, 16)}
+)
+
+func TestEncryptStore(t *testing.T) {
+	inner := newTTLStore()
+	es := New(EncryptStoreOption{Store: inner, Keys: []Key{oldKey}})
+	body := []byte(`{"email":"user@example.com"}`)
+
+	t.Run("Get/Set/Delete", func(t *testing.T) {
+		assert.NoError(t, es.Set("key", body, time.Minute))
+		assert.NotContains(t, string(inner.data["key"]), "user@example.com")
+
+		val, err := es.Get("key")
+		assert.NoError(t, err)
+		assert.Equal(t, body, val)
+
+		assert.NoError(t, es.Delete("key"))
+		val, err = es.Get("key")
+		assert.NoError(t, err)
+		assert.Nil(t, val)
+	})
+
+	t.Run("Value moved to another key", func(t *testing.T) {
+		assert.NoError(t, es.Set("key", body, time.Minute))
+		inner.data["other"] = inner.data["key"]
+
+		_, err := es.Get("other")
+		assert.Error(t, err)
+	})
+
+	t.Run("Tampered value", func(t *testing.T) {
+		val := bytes.Clone(inner.data["key"])
+		val[len(val)-1] ^= 1
+		inner.data["tampered"] = val
+
+		_, err := es.Get("tampered")
+		assert.Error(t, err)
+
+		inner.data["short"] = []byte("OK")
+		_, err = es.Get("short")
+		assert.ErrorIs(t, err, ErrInvalidValue)
+	})
+
+	t.Run("Unknown key", func(t *testing.T) {
+		other := New(EncryptStoreOption{Store: inner, Keys: []Key{newKey}})
+		_, err := other.Get("key")
+		assert.ErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("Multi", func(t *testing.T) {
+		bs := es.(store.BatchStore)
+		items := map[string][]byte{"a": body, "b": []byte("OK")}
+		assert.NoError(t, bs.SetMulti(items, time.Minute))
+		assert.NotContains(t, string(inner.data["a"]), "user@example.com")
+		assert.Equal(t, time.Minute, inner.ttls["a"])
+
+		values, err := bs.GetMulti([]string{"a", "b", "missing"})
+		assert.NoError(t, err)
+		assert.Equal(t, items, values)
+
+		assert.NoError(t, bs.DeleteMulti([]string{"a", "b"}))
+		values, err = bs.GetMulti([]string{"a", "b"})
+		assert.NoError(t, err)
+		assert.Empty(t, values)
+	})
+
+	t.Run("Invalid keys", func(t *testing.T) {
+		assert.Panics(t, func() { New(EncryptStoreOption{Store: inner}) })
+		assert.Panics(t, func() {
+			New(EncryptStoreOption{Store: inner, Keys: []Key{{ID: 1, Secret: []byte("short")}}})
+		})
+		assert.Panics(t, func() {
+			New(EncryptStoreOption{Store: inner, Keys: []Key{oldKey, oldKey}})
+		})
+	})
+}
+
+func TestEncryptStoreRekey(t *testing.T) {
+	inner := newTTLStore()
+	old := New(EncryptStoreOption{Store: inner, Keys: []Key{oldKey}})
+	assert.NoError(t, old.Set("expiring", []byte("A"), time.Hour))
+	assert.NoError(t, old.Set("forever", []byte("B"), 0))
+	before := bytes.Clone(inner.data["forever"])
+
+	es := New(EncryptStoreOption{Store: inner, Keys: []Key{newKey, oldKey}}).(*EncryptStore)
+
+	// old values are still readable
+	val, err := es.Get("expiring")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("A"), val)
+
+	assert.NoError(t, es.Rekey("expiring", "forever", "missing"))
+	assert.NotEqual(t, before, inner.data["forever"])
+	assert.Equal(t, time.Duration(0), inner.ttls["forever"])
+	assert.InDelta(t, time.Hour, inner.ttls["expiring"], float64(time.Second))
+
+	// readable without the old key
+	rekeyed := New(EncryptStoreOption{Store: inner, Keys: []Key{newKey}})
+	val, err = rekeyed.Get("expiring")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("A"), val)
+	val, err = rekeyed.Get("forever")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("B"), val)
+
+	t.Run("Values which can't be decrypted", func(t *testing.T) {
+		inner.data["invalid"] = []byte("OK")
+		assert.ErrorIs(t, es.Rekey("invalid", "forever"), ErrInvalidValue)
+		delete(inner.data, "invalid")
+	})
+
+	t.Run("Value set meanwhile", func(t *testing.T) {
+		assert.NoError(t, old.Set("racing", []byte("OLD"), 0))
+		inner.afterGet = func(key string) {
+			inner.afterGet = nil
+			assert.NoError(t, es.Set(key, []byte("NEW"), 0))
+		}
+		defer func() { inner.afterGet = nil }()
+
+		assert.NoError(t, es.Rekey("racing"))
+		val, err := es.Get("racing")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("NEW"), val)
+	})
+}
+
+func TestEncryptStoreRekeyPrefix(t *testing.T) {
+	inner := memorystore.New(1024)
+	old := New(EncryptStoreOption{Store: inner, Keys: []Key{oldKey}})
+	for i := range 250 {
+		assert.NoError(t, old.Set(fmt.Sprintf("app1-%d", i), []byte("A"), time.Hour))
+	}
+	assert.NoError(t, old.Set("app2-0", []byte("B"), time.Hour))
+
+	es := New(EncryptStoreOption{Store: inner, Keys: []Key{newKey, oldKey}}).(*EncryptStore)
+	assert.NoError(t, es.RekeyPrefix("app1-"))
+
+	rekeyed := New(EncryptStoreOption{Store: inner, Keys: []Key{newKey}})
+	for i := range 250 {
+		val, err := rekeyed.Get(fmt.Sprintf("app1-%d", i))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("A"), val)
+	}
+	_, err := rekeyed.Get("app2-0")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	t.Run("Store doesn't support scan", func(t *testing.T) {
+		es := New(EncryptStoreOption{Store: newTTLStore(), Keys: []Key{newKey}}).(*EncryptStore)
+		assert.ErrorIs(t, es.RekeyPrefix("app1-"), store.ErrNotSupported)
+	})
+}
+
+func TestEncryptStoreTags(t *testing.T) {
+	es := New(EncryptStoreOption{Store: memorystore.New(16), Keys: []Key{oldKey}})
+	ts := es.(store.TagStore)
+
+	assert.NoError(t, es.Set("tagged", []byte("OK"), time.Minute))
+	assert.NoError(t, ts.Tag("tagged", []string{"tag"}, time.Minute))
+	assert.NoError(t, ts.PurgeTag("tag"))
+
+	val, _ := es.Get("tagged")
+	assert.Nil(t, val)
+
+	t.Run("Store doesn't support tags", func(t *testing.T) {
+		es := New(EncryptStoreOption{Store: newTTLStore(), Keys: []Key{oldKey}})
+		assert.ErrorIs(t, es.(store.TagStore).PurgeTag("tag"), store.ErrNotSupported)
+	})
+}
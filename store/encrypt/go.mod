@@ -0,0 +1,20 @@
+module github.com/sdvcrx/echo-cache/store/encrypt
+
+replace github.com/sdvcrx/echo-cache/store => ../
+
+replace github.com/sdvcrx/echo-cache/store/memory => ../memory
+
+go 1.23
+
+require (
+	github.com/sdvcrx/echo-cache/store v0.3.0
+	github.com/sdvcrx/echo-cache/store/memory v0.3.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/phuslu/lru v1.0.18 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/stretchr/testify v1.10.0
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
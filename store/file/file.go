@@ -0,0 +1,522 @@
+package filestore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sdvcrx/echo-cache/store"
+)
+
+const (
+	DefaultCleanupInterval = time.Minute
+
+	// Entries start with this magic, the expiration and the key
+	fileMagic = "ECF1"
+	// Prefix of the files being written
+	tempPrefix = ".tmp-"
+	// Temp files older than this were abandoned by a crash
+	tempMaxAge = time.Hour
+)
+
+var errInvalidFile = errors.New("filestore: invalid entry file")
+
+type FileStoreOption struct {
+	// Stops the background cleanup when done, defaults to context.Background()
+	Ctx context.Context
+	// Root directory of the entries, created when missing
+	Dir string
+	// Total size in bytes above which the least recently used entries
+	// are evicted, zero means no limit
+	MaxSize int64
+	// Interval of the removal of the expired entries,
+	// defaults to DefaultCleanupInterval
+	CleanupInterval time.Duration
+}
+
+// FileStore saves each entry in its own file under a directory tree
+// sharded by the hash of the key
+type FileStore struct {
+	FileStoreOption
+	// Total size of the entry files
+	size     atomic.Int64
+	evicting atomic.Bool
+	ticker   *time.Ticker
+}
+
+func New(option FileStoreOption) store.Store {
+	if option.Ctx == nil {
+		option.Ctx = context.Background()
+	}
+	if option.CleanupInterval == 0 {
+		option.CleanupInterval = DefaultCleanupInterval
+	}
+	if err := os.MkdirAll(option.Dir, 0755); err != nil {
+		log.Fatalln("Failed to create file store directory", err)
+	}
+
+	fa := &FileStore{
+		FileStoreOption: option,
+	}
+	// count the size of the existing entries
+	if err := fa.cleanupExpired(); err != nil {
+		log.Println("Failed to cleanup file store expired entries", err)
+	}
+	fa.startCleanupTicker()
+	return fa
+}
+
+var _ store.Store = (*FileStore)(nil)
+var _ store.StreamStore = (*FileStore)(nil)
+var _ store.Inspector = (*FileStore)(nil)
+var _ store.Clearer = (*FileStore)(nil)
+
+func (fa *FileStore) startCleanupTicker() {
+	fa.ticker = time.NewTicker(fa.CleanupInterval)
+
+	go func() {
+		for {
+			select {
+			case <-fa.Ctx.Done():
+				fa.ticker.Stop()
+				return
+			case <-fa.ticker.C:
+				if err := fa.cleanupExpired(); err != nil {
+					log.Println("Failed to cleanup file store expired entries", err)
+				}
+				fa.evictOnce()
+			}
+		}
+	}()
+}
+
+// path returns the file of key, e.g. `<dir>/2c/26/2c26b46b...`
+func (fa *FileStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(fa.Dir, name[0:2], name[2:4], name)
+}
+
+func writeHeader(w io.Writer, key string, expiresAt int64) error {
+	header := make([]byte, 0, len(fileMagic)+8+4+len(key))
+	header = append(header, fileMagic...)
+	header = binary.BigEndian.AppendUint64(header, uint64(expiresAt))
+	header = binary.BigEndian.AppendUint32(header, uint32(len(key)))
+	header = append(header, key...)
+	_, err := w.Write(header)
+	return err
+}
+
+// readHeader returns the key and the expiration in unix milliseconds,
+// zero means never, of the entry read by r
+func readHeader(r io.Reader) (string, int64, error) {
+	var fixed [len(fileMagic) + 8 + 4]byte
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		return "", 0, errInvalidFile
+	}
+	if string(fixed[:len(fileMagic)]) != fileMagic {
+		return "", 0, errInvalidFile
+	}
+	expiresAt := int64(binary.BigEndian.Uint64(fixed[len(fileMagic):]))
+	key := make([]byte, binary.BigEndian.Uint32(fixed[len(fileMagic)+8:]))
+	if _, err := io.ReadFull(r, key); err != nil {
+		return "", 0, errInvalidFile
+	}
+	return string(key), expiresAt, nil
+}
+
+func expired(expiresAt int64, now time.Time) bool {
+	return expiresAt > 0 && now.UnixMilli() >= expiresAt
+}
+
+// open returns the file of key positioned at the value,
+// or nil when the key is missing or expired
+func (fa *FileStore) open(key string) (*os.File, error) {
+	path := fa.path(key)
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	br := bufio.NewReader(f)
+	fileKey, expiresAt, err := readHeader(br)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	now := time.Now()
+	if fileKey != key || expired(expiresAt, now) {
+		f.Close()
+		return nil, nil
+	}
+	// the buffered reader may have read past the header
+	offset := int64(len(fileMagic) + 8 + 4 + len(fileKey))
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	// the modification time is the last access for the eviction
+	os.Chtimes(path, now, now)
+	return f, nil
+}
+
+func (fa *FileStore) Get(key string) ([]byte, error) {
+	f, err := fa.open(key)
+	if f == nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// GetStream returns a reader of the value which implements io.Seeker,
+// offsets are relative to the start of the value
+func (fa *FileStore) GetStream(key string) (io.ReadCloser, error) {
+	f, err := fa.open(key)
+	if f == nil {
+		return nil, err
+	}
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &valueReader{io.NewSectionReader(f, offset, info.Size()-offset), f}, nil
+}
+
+// valueReader reads the value section of an entry file
+type valueReader struct {
+	*io.SectionReader
+	f *os.File
+}
+
+func (r *valueReader) Close() error {
+	return r.f.Close()
+}
+
+func (fa *FileStore) Set(key string, val []byte, ttl time.Duration) error {
+	return fa.SetStream(key, bytes.NewReader(val), ttl)
+}
+
+// SetStream writes the entry to a temp file which replaces the
+// entry file once complete, readers never see a partial entry
+func (fa *FileStore) SetStream(key string, r io.Reader, ttl time.Duration) error {
+	path := fa.path(key)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl).UnixMilli()
+	}
+	bw := bufio.NewWriter(f)
+	err = writeHeader(bw, key, expiresAt)
+	if err == nil {
+		_, err = io.Copy(bw, r)
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	var size int64
+	if err == nil {
+		size, err = f.Seek(0, io.SeekCurrent)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	var replaced int64
+	if info, err := os.Stat(path); err == nil {
+		replaced = info.Size()
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	fa.size.Add(size - replaced)
+
+	if fa.MaxSize > 0 && fa.size.Load() > fa.MaxSize {
+		go fa.evictOnce()
+	}
+	return nil
+}
+
+func (fa *FileStore) Delete(key string) error {
+	path := fa.path(key)
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	fa.size.Add(-info.Size())
+	return nil
+}
+
+// Size returns the total size in bytes of the entries
+func (fa *FileStore) Size() int64 {
+	return fa.size.Load()
+}
+
+// SizeBytes returns Size
+func (fa *FileStore) SizeBytes() (int64, error) {
+	return fa.size.Load(), nil
+}
+
+// Len walks the directory to count the entries,
+// expired entries are counted until they are removed
+func (fa *FileStore) Len() (int64, error) {
+	var n int64
+	err := fa.walk(func(path string, info fs.FileInfo) error {
+		if !strings.HasPrefix(info.Name(), tempPrefix) {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Scan walks the directory and reads the keys from the entry headers,
+// every page walks all the entries
+func (fa *FileStore) Scan(prefix string, cursor string, count int) ([]string, string, error) {
+	now := time.Now()
+	var keys []string
+	err := fa.walk(func(path string, info fs.FileInfo) error {
+		if strings.HasPrefix(info.Name(), tempPrefix) {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		key, expiresAt, err := readHeader(f)
+		f.Close()
+		if err == nil && !expired(expiresAt, now) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	keys, next := store.ScanKeys(keys, prefix, cursor, count)
+	return keys, next, nil
+}
+
+// Clear walks the directory and removes the entries whose key,
+// read from their header, starts with prefix
+func (fa *FileStore) Clear(prefix string) error {
+	return fa.walk(func(path string, info fs.FileInfo) error {
+		if strings.HasPrefix(info.Name(), tempPrefix) {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		key, _, err := readHeader(f)
+		f.Close()
+		if err != nil || !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		fa.size.Add(-info.Size())
+		return nil
+	})
+}
+
+// walk calls fn with each entry and temp file of the sharded layout,
+// entries removed meanwhile are skipped. Other files and directories
+// under Dir are not the store's and are left out.
+func (fa *FileStore) walk(fn func(path string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(fa.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(fa.Dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		parts := strings.Split(rel, string(filepath.Separator))
+		if d.IsDir() {
+			if len(parts) > 2 || !isHex(d.Name(), 2) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !layoutFile(parts) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		return fn(path, info)
+	})
+}
+
+// layoutFile reports whether the path parts relative to Dir
+// are an entry file of path() or a temp file next to one
+func layoutFile(parts []string) bool {
+	if len(parts) != 3 {
+		return false
+	}
+	name := parts[2]
+	if strings.HasPrefix(name, tempPrefix) {
+		return true
+	}
+	return isHex(name, sha256.Size*2) && name[0:2] == parts[0] && name[2:4] == parts[1]
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range []byte(s) {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// validEntry reports whether the file at path starts with an entry header
+func validEntry(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	_, _, err = readHeader(f)
+	return err == nil
+}
+
+// cleanupExpired removes the expired entries and the abandoned temp files,
+// then recounts the size of the entries. Files without an entry header are kept.
+func (fa *FileStore) cleanupExpired() error {
+	now := time.Now()
+	var total int64
+	err := fa.walk(func(path string, info fs.FileInfo) error {
+		if strings.HasPrefix(info.Name(), tempPrefix) {
+			if now.Sub(info.ModTime()) > tempMaxAge {
+				os.Remove(path)
+			}
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		_, expiresAt, err := readHeader(f)
+		f.Close()
+		if err != nil {
+			// not an entry, leave it alone
+			return nil
+		}
+		if expired(expiresAt, now) {
+			os.Remove(path)
+			return nil
+		}
+		total += info.Size()
+		return nil
+	})
+	fa.size.Store(total)
+	return err
+}
+
+// evictOnce runs evict unless an eviction is already running
+func (fa *FileStore) evictOnce() {
+	if fa.evicting.CompareAndSwap(false, true) {
+		defer fa.evicting.Store(false)
+		fa.evict()
+	}
+}
+
+// evict removes the least recently used entries until the
+// total size goes under 90% of MaxSize
+func (fa *FileStore) evict() {
+	if fa.MaxSize <= 0 || fa.size.Load() <= fa.MaxSize {
+		return
+	}
+
+	type entry struct {
+		path       string
+		size       int64
+		accessedAt time.Time
+	}
+	var entries []entry
+	var total int64
+	err := fa.walk(func(path string, info fs.FileInfo) error {
+		if !strings.HasPrefix(info.Name(), tempPrefix) && validEntry(path) {
+			entries = append(entries, entry{path, info.Size(), info.ModTime()})
+			total += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("Failed to list file store entries", err)
+		return
+	}
+
+	slices.SortFunc(entries, func(a, b entry) int {
+		return a.accessedAt.Compare(b.accessedAt)
+	})
+	target := fa.MaxSize / 10 * 9
+	for _, e := range entries {
+		if total <= target {
+			break
+		}
+		if err := os.Remove(e.path); err == nil || errors.Is(err, fs.ErrNotExist) {
+			total -= e.size
+		}
+	}
+	fa.size.Store(total)
+}
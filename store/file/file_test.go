@@ -0,0 +1,264 @@
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStore(t *testing.T, option FileStoreOption) *FileStore {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	option.Ctx = ctx
+	if option.Dir == "" {
+		option.Dir = t.TempDir()
+	}
+	return New(option).(*FileStore)
+}
+
+func TestFileStore(t *testing.T) {
+	fa := newTestStore(t, FileStoreOption{})
+	key := "cache-GET-/posts"
+	body := []byte("OK")
+
+	t.Run("Get/Set", func(t *testing.T) {
+		assert.NoError(t, fa.Set(key, body, time.Minute))
+
+		val, err := fa.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, body, val)
+
+		// sharded by the hash of the key
+		rel, err := filepath.Rel(fa.Dir, fa.path(key))
+		assert.NoError(t, err)
+		parts := strings.Split(rel, string(filepath.Separator))
+		assert.Len(t, parts, 3)
+		assert.FileExists(t, fa.path(key))
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		assert.NoError(t, fa.Set(key, []byte("NEW"), 0))
+
+		val, err := fa.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("NEW"), val)
+
+		info, _ := os.Stat(fa.path(key))
+		assert.Equal(t, info.Size(), fa.Size())
+	})
+
+	t.Run("Missing key", func(t *testing.T) {
+		val, err := fa.Get("missing")
+		assert.NoError(t, err)
+		assert.Nil(t, val)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		assert.NoError(t, fa.Set("expired", body, 10*time.Millisecond))
+		time.Sleep(20 * time.Millisecond)
+
+		val, err := fa.Get("expired")
+		assert.NoError(t, err)
+		assert.Nil(t, val)
+
+		assert.NoError(t, fa.cleanupExpired())
+		assert.NoFileExists(t, fa.path("expired"))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, fa.Delete(key))
+		assert.NoError(t, fa.Delete(key))
+
+		val, err := fa.Get(key)
+		assert.NoError(t, err)
+		assert.Nil(t, val)
+		assert.Equal(t, int64(0), fa.Size())
+	})
+
+	t.Run("Reopen", func(t *testing.T) {
+		assert.NoError(t, fa.Set(key, body, time.Minute))
+
+		reopened := newTestStore(t, FileStoreOption{Dir: fa.Dir})
+		val, err := reopened.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, body, val)
+		assert.Equal(t, fa.Size(), reopened.Size())
+	})
+
+	t.Run("Cleanup abandoned temp files", func(t *testing.T) {
+		tmp := filepath.Join(filepath.Dir(fa.path(key)), tempPrefix+"abandoned")
+		assert.NoError(t, os.WriteFile(tmp, body, 0644))
+		old := time.Now().Add(-2 * tempMaxAge)
+		assert.NoError(t, os.Chtimes(tmp, old, old))
+
+		assert.NoError(t, fa.cleanupExpired())
+		assert.NoFileExists(t, tmp)
+	})
+}
+
+func TestFileStoreForeignFiles(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "ab", "cd", "abcd"+strings.Repeat("0", 60))
+	foreign := []string{
+		filepath.Join(dir, "README.txt"),
+		filepath.Join(dir, "sub", "data.json"),
+		filepath.Join(dir, "ab", "notes.txt"),
+		// in the layout, without an entry header
+		entry,
+	}
+	for _, path := range foreign {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, bytes.Repeat([]byte("a"), 1000), 0644))
+		old := time.Now().Add(-2 * tempMaxAge)
+		assert.NoError(t, os.Chtimes(path, old, old))
+	}
+
+	fa := newTestStore(t, FileStoreOption{Dir: dir, MaxSize: 1500})
+	assert.Equal(t, int64(0), fa.Size())
+	assert.NoError(t, fa.Set("a", bytes.Repeat([]byte("a"), 1000), 0))
+	assert.NoError(t, fa.Set("b", bytes.Repeat([]byte("a"), 1000), 0))
+	assert.Eventually(t, func() bool {
+		return fa.Size() <= 1500 && !fa.evicting.Load()
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, fa.cleanupExpired())
+	assert.NoError(t, fa.Clear(""))
+
+	for _, path := range foreign {
+		assert.FileExists(t, path)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestFileStoreStream(t *testing.T) {
+	fa := newTestStore(t, FileStoreOption{})
+	body := bytes.Repeat([]byte("0123456789"), 10000)
+
+	assert.NoError(t, fa.SetStream("large", bytes.NewReader(body), time.Minute))
+
+	r, err := fa.GetStream("large")
+	assert.NoError(t, err)
+	b, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+	assert.Equal(t, body, b)
+
+	t.Run("Seek", func(t *testing.T) {
+		r, err := fa.GetStream("large")
+		assert.NoError(t, err)
+		defer r.Close()
+		rs, ok := r.(io.ReadSeeker)
+		assert.True(t, ok)
+
+		size, err := rs.Seek(0, io.SeekEnd)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(body)), size)
+		_, err = rs.Seek(5, io.SeekStart)
+		assert.NoError(t, err)
+		b := make([]byte, 3)
+		_, err = io.ReadFull(rs, b)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("567"), b)
+	})
+
+	t.Run("Missing key", func(t *testing.T) {
+		r, err := fa.GetStream("missing")
+		assert.NoError(t, err)
+		assert.Nil(t, r)
+	})
+
+	t.Run("Reader fails", func(t *testing.T) {
+		r := io.MultiReader(bytes.NewReader(body), failingReader{})
+		assert.Error(t, fa.SetStream("failed", r, time.Minute))
+		assert.NoFileExists(t, fa.path("failed"))
+
+		// no temp file left
+		entries, _ := os.ReadDir(filepath.Dir(fa.path("failed")))
+		for _, e := range entries {
+			assert.False(t, strings.HasPrefix(e.Name(), tempPrefix))
+		}
+	})
+}
+
+func TestFileStoreInspector(t *testing.T) {
+	fa := newTestStore(t, FileStoreOption{})
+	for _, key := range []string{"a-1", "b-1", "b-2", "b-3"} {
+		assert.NoError(t, fa.Set(key, []byte("OK"), time.Minute))
+	}
+	assert.NoError(t, fa.Set("b-0", []byte("OK"), time.Millisecond))
+	time.Sleep(2 * time.Millisecond)
+
+	n, err := fa.Len()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+
+	size, err := fa.SizeBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, fa.Size(), size)
+
+	// expired entries are skipped
+	keys, cursor, err := fa.Scan("b-", "", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b-1", "b-2"}, keys)
+	keys, cursor, err = fa.Scan("b-", cursor, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b-3"}, keys)
+	assert.Equal(t, "", cursor)
+}
+
+func TestFileStoreClear(t *testing.T) {
+	fa := newTestStore(t, FileStoreOption{})
+	for _, key := range []string{"app1-GET-/", "app1-GET-/posts", "app2-GET-/"} {
+		assert.NoError(t, fa.Set(key, []byte("OK"), time.Minute))
+	}
+
+	assert.NoError(t, fa.Clear("app1-"))
+	for key, expected := range map[string][]byte{"app1-GET-/": nil, "app1-GET-/posts": nil, "app2-GET-/": []byte("OK")} {
+		val, err := fa.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, val)
+	}
+
+	assert.NoError(t, fa.Clear(""))
+	n, err := fa.Len()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	assert.Equal(t, int64(0), fa.Size())
+}
+
+func TestFileStoreEviction(t *testing.T) {
+	body := bytes.Repeat([]byte("a"), 1000)
+	fa := newTestStore(t, FileStoreOption{MaxSize: 3500})
+
+	for _, key := range []string{"a", "b", "c"} {
+		assert.NoError(t, fa.Set(key, body, 0))
+		time.Sleep(10 * time.Millisecond)
+	}
+	// a becomes the most recently used
+	_, err := fa.Get("a")
+	assert.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	assert.NoError(t, fa.Set("d", body, 0))
+	assert.Eventually(t, func() bool {
+		return fa.Size() <= 3500 && !fa.evicting.Load()
+	}, time.Second, 10*time.Millisecond)
+
+	for key, exists := range map[string]bool{"a": true, "b": false, "c": true, "d": true} {
+		val, err := fa.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, exists, val != nil, key)
+	}
+}
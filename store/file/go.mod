@@ -0,0 +1,14 @@
+module github.com/sdvcrx/echo-cache/store/file
+
+replace github.com/sdvcrx/echo-cache/store => ../
+
+go 1.23
+
+require github.com/sdvcrx/echo-cache/store v0.3.0
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/stretchr/testify v1.10.0
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,281 @@
+package memcachedstore
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sdvcrx/echo-cache/store"
+)
+
+const (
+	DefaultTimeout      = 500 * time.Millisecond
+	DefaultMaxItemSize  = 1024 * 1024
+	DefaultMaxIdleConns = 2
+
+	// Longest key accepted by memcached, longer keys are hashed
+	maxKeyLength = 250
+	// TTLs longer than this are sent as an absolute unix time
+	maxRelativeTTL = 30 * 24 * time.Hour
+)
+
+var (
+	ErrValueTooLarge = errors.New("memcachedstore: value exceeds the item size limit")
+	ErrNoServers     = errors.New("memcachedstore: no servers")
+)
+
+type MemcachedStoreOption struct {
+	// Addresses of the servers, e.g. `localhost:11211`
+	Servers []string
+	// Timeout of dialing and of each operation, defaults to DefaultTimeout
+	Timeout time.Duration
+	// Largest value accepted, must match the `-I` option of the servers,
+	// defaults to DefaultMaxItemSize
+	MaxItemSize int
+	// Idle connections kept per server, defaults to DefaultMaxIdleConns
+	MaxIdleConns int
+}
+
+// MemcachedStore talks to memcached with the meta protocol,
+// keys are spread over the servers with consistent hashing
+type MemcachedStore struct {
+	MemcachedStoreOption
+	ring *ring
+}
+
+type server struct {
+	addr string
+	idle chan *conn
+}
+
+type conn struct {
+	nc net.Conn
+	rw *bufio.ReadWriter
+}
+
+func New(option MemcachedStoreOption) store.Store {
+	if option.Timeout == 0 {
+		option.Timeout = DefaultTimeout
+	}
+	if option.MaxItemSize == 0 {
+		option.MaxItemSize = DefaultMaxItemSize
+	}
+	if option.MaxIdleConns == 0 {
+		option.MaxIdleConns = DefaultMaxIdleConns
+	}
+
+	servers := make([]*server, len(option.Servers))
+	for i, addr := range option.Servers {
+		servers[i] = &server{addr: addr, idle: make(chan *conn, option.MaxIdleConns)}
+	}
+	return &MemcachedStore{
+		MemcachedStoreOption: option,
+		ring:                 newRing(servers),
+	}
+}
+
+var _ store.Store = (*MemcachedStore)(nil)
+var _ store.ContextStore = (*MemcachedStore)(nil)
+
+// serverKey returns key when memcached accepts it, otherwise its hash
+func serverKey(key string) string {
+	valid := len(key) > 0 && len(key) <= maxKeyLength
+	for i := 0; valid && i < len(key); i++ {
+		valid = key[i] > ' ' && key[i] != 0x7f
+	}
+	if valid {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// expiration converts ttl to memcached's exptime: zero means never,
+// up to 30 days it is relative seconds, above an absolute unix time
+func expiration(ttl time.Duration, now time.Time) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	if ttl > maxRelativeTTL {
+		return now.Add(ttl).Unix()
+	}
+	// round up, less than a second must not become never
+	return int64((ttl + time.Second - 1) / time.Second)
+}
+
+func (ma *MemcachedStore) dial(ctx context.Context, s *server) (*conn, error) {
+	select {
+	case c := <-s.idle:
+		return c, nil
+	default:
+	}
+	d := net.Dialer{Timeout: ma.Timeout}
+	nc, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{nc: nc, rw: bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc))}, nil
+}
+
+func (ma *MemcachedStore) release(s *server, c *conn) {
+	select {
+	case s.idle <- c:
+	default:
+		c.nc.Close()
+	}
+}
+
+// do runs fn with a connection to the server of key. The connection
+// is closed when fn fails since the stream may be out of sync.
+func (ma *MemcachedStore) do(ctx context.Context, key string, fn func(rw *bufio.ReadWriter) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(ma.ring.points) == 0 {
+		return ErrNoServers
+	}
+	s := ma.ring.pick(key)
+	c, err := ma.dial(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(ma.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.nc.SetDeadline(deadline)
+	// interrupt the operation when ctx is canceled
+	stop := context.AfterFunc(ctx, func() {
+		c.nc.SetDeadline(time.Unix(1, 0))
+	})
+	err = fn(c.rw)
+	stop()
+
+	if err != nil {
+		c.nc.Close()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	ma.release(s, c)
+	return nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// responseError converts an error line of the server
+func responseError(line string) error {
+	if strings.Contains(line, "too large") {
+		return ErrValueTooLarge
+	}
+	return fmt.Errorf("memcachedstore: unexpected response %q", line)
+}
+
+func (ma *MemcachedStore) Get(key string) ([]byte, error) {
+	return ma.GetContext(context.Background(), key)
+}
+
+func (ma *MemcachedStore) GetContext(ctx context.Context, key string) ([]byte, error) {
+	key = serverKey(key)
+	var val []byte
+	err := ma.do(ctx, key, func(rw *bufio.ReadWriter) error {
+		if _, err := fmt.Fprintf(rw, "mg %s v\r\n", key); err != nil {
+			return err
+		}
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+		line, err := readLine(rw.Reader)
+		if err != nil {
+			return err
+		}
+		if line == "EN" {
+			return nil
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "VA" {
+			return responseError(line)
+		}
+		size, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return responseError(line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rw, buf); err != nil {
+			return err
+		}
+		val = buf[:size]
+		return nil
+	})
+	return val, err
+}
+
+func (ma *MemcachedStore) Set(key string, val []byte, ttl time.Duration) error {
+	return ma.SetContext(context.Background(), key, val, ttl)
+}
+
+func (ma *MemcachedStore) SetContext(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	// the item also holds the key and a header
+	if len(val)+len(key)+64 > ma.MaxItemSize {
+		return ErrValueTooLarge
+	}
+	key = serverKey(key)
+	return ma.do(ctx, key, func(rw *bufio.ReadWriter) error {
+		fmt.Fprintf(rw, "ms %s %d T%d\r\n", key, len(val), expiration(ttl, time.Now()))
+		rw.Write(val)
+		rw.WriteString("\r\n")
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+		line, err := readLine(rw.Reader)
+		if err != nil {
+			return err
+		}
+		if line != "HD" {
+			return responseError(line)
+		}
+		return nil
+	})
+}
+
+func (ma *MemcachedStore) Delete(key string) error {
+	return ma.DeleteContext(context.Background(), key)
+}
+
+func (ma *MemcachedStore) DeleteContext(ctx context.Context, key string) error {
+	key = serverKey(key)
+	return ma.do(ctx, key, func(rw *bufio.ReadWriter) error {
+		if _, err := fmt.Fprintf(rw, "md %s\r\n", key); err != nil {
+			return err
+		}
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+		line, err := readLine(rw.Reader)
+		if err != nil {
+			return err
+		}
+		// NF: not found
+		if line != "HD" && line != "NF" {
+			return responseError(line)
+		}
+		return nil
+	})
+}
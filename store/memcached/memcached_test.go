@@ -0,0 +1,254 @@
+package memcachedstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeItem struct {
+	value []byte
+	// zero means never
+	expiresAt time.Time
+}
+
+// fakeServer speaks the subset of the memcached meta protocol used by the store
+type fakeServer struct {
+	ln          net.Listener
+	maxItemSize int
+
+	mu    sync.Mutex
+	items map[string]fakeItem
+	// exptime received by the last ms command
+	exptime int64
+}
+
+func startFakeServer(t *testing.T) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{ln: ln, maxItemSize: DefaultMaxItemSize, items: map[string]fakeItem{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(nc)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *fakeServer) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
+
+func (s *fakeServer) serve(nc net.Conn) {
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			fmt.Fprint(nc, "ERROR\r\n")
+			continue
+		}
+		s.mu.Lock()
+		switch fields[0] {
+		case "mg":
+			item, ok := s.items[fields[1]]
+			if ok && !item.expiresAt.IsZero() && time.Now().After(item.expiresAt) {
+				delete(s.items, fields[1])
+				ok = false
+			}
+			if ok {
+				fmt.Fprintf(nc, "VA %d\r\n%s\r\n", len(item.value), item.value)
+			} else {
+				fmt.Fprint(nc, "EN\r\n")
+			}
+		case "ms":
+			size, _ := strconv.Atoi(fields[2])
+			data := make([]byte, size+2)
+			io.ReadFull(r, data)
+			var exptime int64
+			for _, flag := range fields[3:] {
+				if strings.HasPrefix(flag, "T") {
+					exptime, _ = strconv.ParseInt(flag[1:], 10, 64)
+				}
+			}
+			s.exptime = exptime
+			if size > s.maxItemSize {
+				fmt.Fprint(nc, "SERVER_ERROR object too large for cache\r\n")
+				break
+			}
+			item := fakeItem{value: data[:size]}
+			switch {
+			case exptime > int64(maxRelativeTTL/time.Second):
+				item.expiresAt = time.Unix(exptime, 0)
+			case exptime > 0:
+				item.expiresAt = time.Now().Add(time.Duration(exptime) * time.Second)
+			}
+			s.items[fields[1]] = item
+			fmt.Fprint(nc, "HD\r\n")
+		case "md":
+			if _, ok := s.items[fields[1]]; ok {
+				delete(s.items, fields[1])
+				fmt.Fprint(nc, "HD\r\n")
+			} else {
+				fmt.Fprint(nc, "NF\r\n")
+			}
+		default:
+			fmt.Fprint(nc, "ERROR\r\n")
+		}
+		s.mu.Unlock()
+	}
+}
+
+func TestMemcachedStore(t *testing.T) {
+	srv := startFakeServer(t)
+	ma := New(MemcachedStoreOption{Servers: []string{srv.addr()}})
+	key := "cache-GET-/posts?page=1"
+	body := []byte("OK\r\nEND")
+
+	t.Run("Get/Set/Delete", func(t *testing.T) {
+		assert.NoError(t, ma.Set(key, body, time.Minute))
+
+		val, err := ma.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, body, val)
+
+		assert.NoError(t, ma.Delete(key))
+		assert.NoError(t, ma.Delete(key))
+		val, err = ma.Get(key)
+		assert.NoError(t, err)
+		assert.Nil(t, val)
+	})
+
+	t.Run("Invalid keys are hashed", func(t *testing.T) {
+		for _, key := range []string{"with space", strings.Repeat("k", 300)} {
+			assert.NoError(t, ma.Set(key, body, time.Minute))
+			val, err := ma.Get(key)
+			assert.NoError(t, err)
+			assert.Equal(t, body, val)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		assert.NoError(t, ma.Set("expiring", body, time.Millisecond))
+		assert.Equal(t, int64(1), srv.exptime)
+
+		assert.NoError(t, ma.Set("never", body, 0))
+		assert.Equal(t, int64(0), srv.exptime)
+
+		ttl := 60 * 24 * time.Hour
+		assert.NoError(t, ma.Set("absolute", body, ttl))
+		assert.InDelta(t, time.Now().Add(ttl).Unix(), srv.exptime, 1)
+		val, err := ma.Get("absolute")
+		assert.NoError(t, err)
+		assert.Equal(t, body, val)
+	})
+
+	t.Run("Value too large", func(t *testing.T) {
+		large := bytes.Repeat([]byte("a"), DefaultMaxItemSize)
+		assert.ErrorIs(t, ma.Set(key, large, time.Minute), ErrValueTooLarge)
+
+		// rejected by the server
+		srv.maxItemSize = 1024
+		defer func() { srv.maxItemSize = DefaultMaxItemSize }()
+		assert.ErrorIs(t, ma.Set(key, large[:2048], time.Minute), ErrValueTooLarge)
+
+		// the connection is still usable
+		assert.NoError(t, ma.Set(key, body, time.Minute))
+	})
+
+	t.Run("Context done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := ma.(*MemcachedStore).GetContext(ctx, key)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("No servers", func(t *testing.T) {
+		_, err := New(MemcachedStoreOption{}).Get(key)
+		assert.ErrorIs(t, err, ErrNoServers)
+	})
+}
+
+func TestExpiration(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	assert.Equal(t, int64(0), expiration(0, now))
+	assert.Equal(t, int64(0), expiration(-time.Second, now))
+	assert.Equal(t, int64(1), expiration(time.Millisecond, now))
+	assert.Equal(t, int64(2), expiration(1500*time.Millisecond, now))
+	assert.Equal(t, int64(30*24*3600), expiration(maxRelativeTTL, now))
+	assert.Equal(t, now.Unix()+31*24*3600, expiration(31*24*time.Hour, now))
+}
+
+func TestMemcachedStoreServers(t *testing.T) {
+	servers := []*fakeServer{startFakeServer(t), startFakeServer(t), startFakeServer(t)}
+	addrs := make([]string, len(servers))
+	for i, srv := range servers {
+		addrs[i] = srv.addr()
+	}
+	ma := New(MemcachedStoreOption{Servers: addrs}).(*MemcachedStore)
+
+	keys := make([]string, 300)
+	for i := range keys {
+		keys[i] = "cache-GET-/posts/" + strconv.Itoa(i)
+		assert.NoError(t, ma.Set(keys[i], []byte("OK"), time.Minute))
+	}
+	for _, srv := range servers {
+		assert.Greater(t, srv.len(), 0)
+	}
+	for _, key := range keys {
+		val, err := ma.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("OK"), val)
+	}
+
+	t.Run("Spread over every server", func(t *testing.T) {
+		// fixed addresses so the ring is the same on every run
+		r := newRing([]*server{{addr: "10.0.0.1:11211"}, {addr: "10.0.0.2:11211"}, {addr: "10.0.0.3:11211"}})
+		counts := map[string]int{}
+		for _, key := range keys {
+			counts[r.pick(key).addr]++
+		}
+		assert.Len(t, counts, 3)
+		for _, n := range counts {
+			assert.Greater(t, n, 50)
+		}
+	})
+
+	t.Run("Removing a server only moves its keys", func(t *testing.T) {
+		smaller := New(MemcachedStoreOption{Servers: addrs[:2]}).(*MemcachedStore)
+		for _, key := range keys {
+			before := ma.ring.pick(key).addr
+			if before != addrs[2] {
+				assert.Equal(t, before, smaller.ring.pick(key).addr)
+			}
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package memcachedstore
+
+import (
+	"cmp"
+	"hash/crc32"
+	"slices"
+	"strconv"
+)
+
+// Points of each server on the ring, more points spread the keys evenly
+const ringReplicas = 160
+
+type ringPoint struct {
+	hash   uint32
+	server *server
+}
+
+// ring maps keys to servers with consistent hashing,
+// adding or removing a server only moves the keys of its points
+type ring struct {
+	points []ringPoint
+}
+
+func newRing(servers []*server) *ring {
+	r := &ring{points: make([]ringPoint, 0, len(servers)*ringReplicas)}
+	for _, s := range servers {
+		for i := range ringReplicas {
+			h := crc32.ChecksumIEEE([]byte(s.addr + "-" + strconv.Itoa(i)))
+			r.points = append(r.points, ringPoint{h, s})
+		}
+	}
+	slices.SortFunc(r.points, func(a, b ringPoint) int {
+		return cmp.Compare(a.hash, b.hash)
+	})
+	return r
+}
+
+// pick returns the server of the first point after the hash of key
+func (r *ring) pick(key string) *server {
+	h := crc32.ChecksumIEEE([]byte(key))
+	i, _ := slices.BinarySearchFunc(r.points, h, func(p ringPoint, h uint32) int {
+		return cmp.Compare(p.hash, h)
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].server
+}
@@ -1,6 +1,11 @@
 package memorystore
 
 import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/phuslu/lru"
@@ -9,14 +14,101 @@ import (
 
 type MemoryStore struct {
 	cache *lru.TTLCache[string, []byte]
+	tags  *tagIndex
+}
+
+// Interval between two sweeps of every tag of a tagIndex
+const tagPruneInterval = time.Minute
+
+type tagIndex struct {
+	mu sync.Mutex
+	// tag -> key -> expiration in unix nanoseconds, zero means never
+	keys map[string]map[string]int64
+	// reports whether key is still saved, evicted keys are dropped by sweeps
+	exists func(key string) bool
+	// unix nanoseconds of the start of the last sweep
+	prunedAt atomic.Int64
+	sweeping atomic.Bool
+}
+
+func newTagIndex(exists func(key string) bool) *tagIndex {
+	return &tagIndex{keys: make(map[string]map[string]int64), exists: exists}
+}
+
+func (ti *tagIndex) add(key string, tags []string, ttl time.Duration) {
+	now := time.Now().UnixNano()
+	var expiredAt int64
+	if ttl > 0 {
+		expiredAt = now + ttl.Nanoseconds()
+	}
+
+	ti.mu.Lock()
+	for _, tag := range tags {
+		keys, ok := ti.keys[tag]
+		if !ok {
+			keys = make(map[string]int64)
+			ti.keys[tag] = keys
+		}
+		keys[key] = expiredAt
+	}
+	ti.mu.Unlock()
+
+	// drop expired and evicted keys so the index doesn't grow forever
+	if now-ti.prunedAt.Load() >= int64(tagPruneInterval) && ti.sweeping.CompareAndSwap(false, true) {
+		ti.prunedAt.Store(now)
+		go ti.sweep()
+	}
+}
+
+// sweep drops the keys which expired or are not saved anymore, and the
+// tags left without keys. The lock is taken once per tag so saves go on.
+func (ti *tagIndex) sweep() {
+	defer ti.sweeping.Store(false)
+
+	ti.mu.Lock()
+	tags := slices.Collect(maps.Keys(ti.keys))
+	ti.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	for _, tag := range tags {
+		ti.mu.Lock()
+		keys := ti.keys[tag]
+		for k, exp := range keys {
+			if (exp > 0 && exp <= now) || !ti.exists(k) {
+				delete(keys, k)
+			}
+		}
+		if keys != nil && len(keys) == 0 {
+			delete(ti.keys, tag)
+		}
+		ti.mu.Unlock()
+	}
+}
+
+// remove drops tag from the index and returns its keys
+func (ti *tagIndex) remove(tag string) map[string]int64 {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+
+	keys := ti.keys[tag]
+	delete(ti.keys, tag)
+	return keys
 }
 
 var _ store.Store = (*MemoryStore)(nil)
+var _ store.TagStore = (*MemoryStore)(nil)
+var _ store.Inspector = (*MemoryStore)(nil)
+var _ store.Clearer = (*MemoryStore)(nil)
 
 func New(size int) store.Store {
-	return &MemoryStore{
+	ma := &MemoryStore{
 		cache: lru.NewTTLCache[string, []byte](size),
 	}
+	ma.tags = newTagIndex(func(key string) bool {
+		_, _, ok := ma.cache.Peek(key)
+		return ok
+	})
+	return ma
 }
 
 func (ma *MemoryStore) Get(key string) ([]byte, error) {
@@ -29,3 +121,48 @@ func (ma *MemoryStore) Set(key string, val []byte, ttl time.Duration) error {
 	ma.cache.Set(key, val, ttl)
 	return nil
 }
+
+func (ma *MemoryStore) Delete(key string) error {
+	ma.cache.Delete(key)
+	return nil
+}
+
+func (ma *MemoryStore) Tag(key string, tags []string, ttl time.Duration) error {
+	ma.tags.add(key, tags, ttl)
+	return nil
+}
+
+func (ma *MemoryStore) PurgeTag(tag string) error {
+	keys := ma.tags.remove(tag)
+	for key := range keys {
+		ma.cache.Delete(key)
+	}
+	return nil
+}
+
+func (ma *MemoryStore) Len() (int64, error) {
+	return int64(ma.cache.Len()), nil
+}
+
+func (ma *MemoryStore) SizeBytes() (int64, error) {
+	var size int64
+	for _, key := range ma.cache.AppendKeys(nil) {
+		val, _, _ := ma.cache.Peek(key)
+		size += int64(len(key) + len(val))
+	}
+	return size, nil
+}
+
+func (ma *MemoryStore) Scan(prefix string, cursor string, count int) ([]string, string, error) {
+	keys, next := store.ScanKeys(ma.cache.AppendKeys(nil), prefix, cursor, count)
+	return keys, next, nil
+}
+
+func (ma *MemoryStore) Clear(prefix string) error {
+	for _, key := range ma.cache.AppendKeys(nil) {
+		if strings.HasPrefix(key, prefix) {
+			ma.cache.Delete(key)
+		}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/sdvcrx/echo-cache/store"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -27,6 +28,19 @@ func TestNewMemoryStore(t *testing.T) {
 		assert.Nil(t, r)
 	})
 
+	t.Run("Delete", func(t *testing.T) {
+		key := "deleted"
+		assert.NoError(t, cache.Set(key, body, time.Minute))
+		assert.NoError(t, cache.Delete(key))
+
+		r, err := cache.Get(key)
+		assert.NoError(t, err)
+		assert.Nil(t, r)
+
+		// delete a missing key
+		assert.NoError(t, cache.Delete(key))
+	})
+
 	t.Run("Set val expired", func(t *testing.T) {
 		ttl := 2 * time.Second
 		key := "expired"
@@ -54,3 +68,105 @@ func TestNewMemoryStore(t *testing.T) {
 		assert.Equal(t, body, r)
 	})
 }
+
+func TestMemoryStoreTags(t *testing.T) {
+	cache := New(20).(*MemoryStore)
+	body := []byte("OK")
+
+	t.Run("PurgeTag", func(t *testing.T) {
+		for _, key := range []string{"a", "b", "c"} {
+			assert.NoError(t, cache.Set(key, body, time.Minute))
+		}
+		assert.NoError(t, cache.Tag("a", []string{"product:1", "product:2"}, time.Minute))
+		assert.NoError(t, cache.Tag("b", []string{"product:1"}, time.Minute))
+
+		assert.NoError(t, cache.PurgeTag("product:1"))
+		for key, expected := range map[string][]byte{"a": nil, "b": nil, "c": body} {
+			r, err := cache.Get(key)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, r)
+		}
+
+		// purge a missing tag
+		assert.NoError(t, cache.PurgeTag("product:3"))
+	})
+
+	t.Run("Sweep tags", func(t *testing.T) {
+		for _, key := range []string{"expired", "deleted", "kept"} {
+			assert.NoError(t, cache.Set(key, body, time.Minute))
+		}
+		assert.NoError(t, cache.Tag("expired", []string{"product:4"}, time.Millisecond))
+		assert.NoError(t, cache.Tag("deleted", []string{"product:5"}, time.Minute))
+		assert.NoError(t, cache.Tag("kept", []string{"product:5", "product:6"}, time.Minute))
+		assert.NoError(t, cache.Delete("deleted"))
+		time.Sleep(2 * time.Millisecond)
+
+		// force the next Tag to sweep every tag
+		assert.Eventually(t, func() bool { return !cache.tags.sweeping.Load() }, time.Second, time.Millisecond)
+		cache.tags.prunedAt.Store(0)
+		assert.NoError(t, cache.Tag("kept", []string{"product:7"}, time.Minute))
+		assert.Eventually(t, func() bool { return !cache.tags.sweeping.Load() }, time.Second, time.Millisecond)
+
+		cache.tags.mu.Lock()
+		defer cache.tags.mu.Unlock()
+		assert.NotContains(t, cache.tags.keys, "product:4")
+		assert.Len(t, cache.tags.keys["product:5"], 1)
+		assert.Contains(t, cache.tags.keys["product:5"], "kept")
+		assert.Contains(t, cache.tags.keys, "product:6")
+		assert.Contains(t, cache.tags.keys, "product:7")
+	})
+}
+
+func TestMemoryStoreInspector(t *testing.T) {
+	for name, cache := range map[string]store.Inspector{
+		"MemoryStore":  New(20).(*MemoryStore),
+		"ShardedStore": NewSharded(ShardedStoreOption{MaxBytes: 1 << 20}).(*ShardedStore),
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"a-1", "b-1", "b-2", "b-3"} {
+				assert.NoError(t, cache.Set(key, []byte("OK"), time.Minute))
+			}
+
+			n, err := cache.Len()
+			assert.NoError(t, err)
+			assert.Equal(t, int64(4), n)
+
+			size, err := cache.SizeBytes()
+			assert.NoError(t, err)
+			assert.Equal(t, int64(4*(3+2)), size)
+
+			keys, cursor, err := cache.Scan("b-", "", 2)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"b-1", "b-2"}, keys)
+			keys, cursor, err = cache.Scan("b-", cursor, 2)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"b-3"}, keys)
+			assert.Equal(t, "", cursor)
+		})
+	}
+}
+
+func TestMemoryStoreClear(t *testing.T) {
+	for name, cache := range map[string]store.Clearer{
+		"MemoryStore":  New(20).(*MemoryStore),
+		"ShardedStore": NewSharded(ShardedStoreOption{MaxBytes: 1 << 20}).(*ShardedStore),
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"app1-GET-/", "app1-GET-/posts", "app2-GET-/"} {
+				assert.NoError(t, cache.Set(key, []byte("OK"), time.Minute))
+			}
+
+			assert.NoError(t, cache.Clear("app1-"))
+			for key, expected := range map[string][]byte{"app1-GET-/": nil, "app1-GET-/posts": nil, "app2-GET-/": []byte("OK")} {
+				r, err := cache.Get(key)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, r)
+			}
+
+			assert.NoError(t, cache.Clear(""))
+			r, err := cache.Get("app2-GET-/")
+			assert.NoError(t, err)
+			assert.Nil(t, r)
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package memorystore
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+// Policy decides which entries a ShardedStore evicts when it is full
+type Policy int
+
+const (
+	// Evict the least recently used entries
+	LRU Policy = iota
+	// Evict the least frequently used entries
+	LFU
+	// Admit new entries only when they are used more often than the
+	// entries they would evict, see https://arxiv.org/abs/1512.00727
+	TinyLFU
+)
+
+type entry struct {
+	key  string
+	hash uint64
+	val  []byte
+	size int64
+	// unix nanoseconds, zero means never
+	expiresAt int64
+
+	// LRU and TinyLFU
+	elem    *list.Element
+	segment segment
+	// LFU
+	index int
+	freq  uint64
+	tick  uint64
+}
+
+func (e *entry) expired(now int64) bool {
+	return e.expiresAt > 0 && e.expiresAt <= now
+}
+
+// policy tracks the entries of a shard and evicts them to keep its size
+// within the budget
+type policy interface {
+	// add tracks e, then returns the entries evicted to make room
+	// and the entries refused, which may include e
+	add(e *entry) (evicted, rejected []*entry)
+	hit(e *entry)
+	miss(hash uint64)
+	remove(e *entry)
+}
+
+func newPolicy(p Policy, maxBytes int64) policy {
+	switch p {
+	case LRU:
+		return &lruPolicy{maxBytes: maxBytes, ll: list.New()}
+	case LFU:
+		return &lfuPolicy{maxBytes: maxBytes}
+	case TinyLFU:
+		return newTinyLFUPolicy(maxBytes)
+	}
+	panic("memorystore: unknown policy")
+}
+
+type lruPolicy struct {
+	maxBytes int64
+	bytes    int64
+	ll       *list.List
+}
+
+func (p *lruPolicy) add(e *entry) (evicted, rejected []*entry) {
+	e.elem = p.ll.PushFront(e)
+	p.bytes += e.size
+	for p.bytes > p.maxBytes {
+		victim := p.ll.Back().Value.(*entry)
+		p.remove(victim)
+		evicted = append(evicted, victim)
+	}
+	return evicted, nil
+}
+
+func (p *lruPolicy) hit(e *entry) {
+	p.ll.MoveToFront(e.elem)
+}
+
+func (p *lruPolicy) miss(hash uint64) {}
+
+func (p *lruPolicy) remove(e *entry) {
+	p.ll.Remove(e.elem)
+	p.bytes -= e.size
+}
+
+// lfuPolicy evicts the entry with the fewest hits,
+// the least recently used one among equals
+type lfuPolicy struct {
+	maxBytes int64
+	bytes    int64
+	entries  lfuHeap
+	tick     uint64
+}
+
+func (p *lfuPolicy) add(e *entry) (evicted, rejected []*entry) {
+	// evict before pushing e, which would otherwise be the first victim
+	for p.bytes+e.size > p.maxBytes && len(p.entries) > 0 {
+		victim := p.entries[0]
+		p.remove(victim)
+		evicted = append(evicted, victim)
+	}
+	p.tick++
+	e.tick = p.tick
+	heap.Push(&p.entries, e)
+	p.bytes += e.size
+	return evicted, nil
+}
+
+func (p *lfuPolicy) hit(e *entry) {
+	p.tick++
+	e.tick = p.tick
+	e.freq++
+	heap.Fix(&p.entries, e.index)
+}
+
+func (p *lfuPolicy) miss(hash uint64) {}
+
+func (p *lfuPolicy) remove(e *entry) {
+	heap.Remove(&p.entries, e.index)
+	p.bytes -= e.size
+}
+
+type lfuHeap []*entry
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x any) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
+
+type segment uint8
+
+const (
+	window segment = iota
+	probation
+	protected
+)
+
+// tinyLFUPolicy is W-TinyLFU: new entries go to a small LRU window,
+// entries leaving the window are admitted to the main SLRU only when
+// the sketch estimates they are used more often than its victims.
+type tinyLFUPolicy struct {
+	sketch *sketch
+	lists  [3]*list.List
+	bytes  [3]int64
+	// window, main and protected budgets
+	maxWindow    int64
+	maxMain      int64
+	maxProtected int64
+}
+
+// Share of the budget given to the window, and of the main space given
+// to protected entries
+const (
+	windowRatio    = 0.01
+	protectedRatio = 0.8
+)
+
+// Expected average entry size, used to size the sketch
+const averageEntrySize = 4 << 10
+
+func newTinyLFUPolicy(maxBytes int64) *tinyLFUPolicy {
+	maxWindow := max(int64(float64(maxBytes)*windowRatio), 1)
+	maxMain := maxBytes - maxWindow
+	p := &tinyLFUPolicy{
+		sketch:       newSketch(int(maxBytes / averageEntrySize)),
+		maxWindow:    maxWindow,
+		maxMain:      maxMain,
+		maxProtected: int64(float64(maxMain) * protectedRatio),
+	}
+	for i := range p.lists {
+		p.lists[i] = list.New()
+	}
+	return p
+}
+
+func (p *tinyLFUPolicy) push(e *entry, seg segment) {
+	e.segment = seg
+	e.elem = p.lists[seg].PushFront(e)
+	p.bytes[seg] += e.size
+}
+
+func (p *tinyLFUPolicy) add(e *entry) (evicted, rejected []*entry) {
+	p.sketch.increment(e.hash)
+	p.push(e, window)
+	for p.bytes[window] > p.maxWindow {
+		candidate := p.lists[window].Back().Value.(*entry)
+		p.remove(candidate)
+		victims, ok := p.admit(candidate)
+		evicted = append(evicted, victims...)
+		if !ok {
+			rejected = append(rejected, candidate)
+		}
+	}
+	return evicted, rejected
+}
+
+// admit moves candidate to the main space, evicting the entries it needs
+// room from when they are used less often than it
+func (p *tinyLFUPolicy) admit(candidate *entry) (evicted []*entry, ok bool) {
+	if candidate.size > p.maxMain {
+		return nil, false
+	}
+
+	need := p.bytes[probation] + p.bytes[protected] + candidate.size - p.maxMain
+	freq := p.sketch.estimate(candidate.hash)
+	var victims []*entry
+	for _, seg := range []segment{probation, protected} {
+		for elem := p.lists[seg].Back(); elem != nil && need > 0; elem = elem.Prev() {
+			victim := elem.Value.(*entry)
+			if p.sketch.estimate(victim.hash) >= freq {
+				return nil, false
+			}
+			victims = append(victims, victim)
+			need -= victim.size
+		}
+	}
+
+	for _, victim := range victims {
+		p.remove(victim)
+	}
+	p.push(candidate, probation)
+	return victims, true
+}
+
+func (p *tinyLFUPolicy) hit(e *entry) {
+	p.sketch.increment(e.hash)
+	switch e.segment {
+	case window, protected:
+		p.lists[e.segment].MoveToFront(e.elem)
+	case probation:
+		p.remove(e)
+		p.push(e, protected)
+		for p.bytes[protected] > p.maxProtected {
+			demoted := p.lists[protected].Back().Value.(*entry)
+			p.remove(demoted)
+			p.push(demoted, probation)
+		}
+	}
+}
+
+func (p *tinyLFUPolicy) miss(hash uint64) {
+	p.sketch.increment(hash)
+}
+
+func (p *tinyLFUPolicy) remove(e *entry) {
+	p.lists[e.segment].Remove(e.elem)
+	p.bytes[e.segment] -= e.size
+}
+
+// sketch is a count-min sketch of 4-bit counters, halved periodically
+// so the frequencies of old entries decay
+type sketch struct {
+	rows      [4][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+var sketchSeeds = [4]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325}
+
+func newSketch(entries int) *sketch {
+	width := 1024
+	for width < entries && width < 1<<24 {
+		width <<= 1
+	}
+	s := &sketch{mask: uint64(width - 1), resetAt: 10 * width}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *sketch) index(hash uint64, row int) uint64 {
+	h := (hash ^ sketchSeeds[row]) * 0x9e3779b97f4a7c15
+	h ^= h >> 32
+	return h & s.mask
+}
+
+func (s *sketch) increment(hash uint64) {
+	for i := range s.rows {
+		if idx := s.index(hash, i); s.rows[i][idx] < 15 {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *sketch) estimate(hash uint64) uint8 {
+	freq := uint8(15)
+	for i := range s.rows {
+		freq = min(freq, s.rows[i][s.index(hash, i)])
+	}
+	return freq
+}
+
+func (s *sketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
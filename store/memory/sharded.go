@@ -0,0 +1,259 @@
+package memorystore
+
+import (
+	"hash/maphash"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sdvcrx/echo-cache/store"
+)
+
+const DefaultShards = 16
+
+type ShardedStoreOption struct {
+	// Total size in bytes of the keys and values kept, split evenly
+	// between the shards. Values larger than a shard are not kept.
+	MaxBytes int64
+	// Number of shards, rounded up to a power of two. Defaults to DefaultShards.
+	Shards int
+	// Eviction policy, defaults to LRU
+	Policy Policy
+}
+
+// ShardStats are the counters of a shard of a ShardedStore
+type ShardStats struct {
+	// Number and size of the entries kept, expired entries
+	// are counted until they are read or evicted
+	Len   int
+	Bytes int64
+
+	Hits   uint64
+	Misses uint64
+	// Entries evicted to make room for others
+	Evictions uint64
+	// Entries the policy refused to keep
+	Rejections uint64
+}
+
+// ShardedStore is a memory store bounded by the size of its entries
+// rather than their number
+type ShardedStore struct {
+	seed   maphash.Seed
+	shards []*shard
+	tags   *tagIndex
+}
+
+type shard struct {
+	mu       sync.Mutex
+	maxBytes int64
+	items    map[string]*entry
+	policy   policy
+	stats    ShardStats
+}
+
+var _ store.Store = (*ShardedStore)(nil)
+var _ store.TagStore = (*ShardedStore)(nil)
+var _ store.Inspector = (*ShardedStore)(nil)
+var _ store.Clearer = (*ShardedStore)(nil)
+
+func NewSharded(option ShardedStoreOption) store.Store {
+	if option.MaxBytes <= 0 {
+		panic("memorystore: MaxBytes must be positive")
+	}
+	if option.Shards <= 0 {
+		option.Shards = DefaultShards
+	}
+	n := 1
+	for n < option.Shards {
+		n <<= 1
+	}
+
+	ss := &ShardedStore{
+		seed:   maphash.MakeSeed(),
+		shards: make([]*shard, n),
+	}
+	ss.tags = newTagIndex(ss.exists)
+	for i := range ss.shards {
+		maxBytes := max(option.MaxBytes/int64(n), 1)
+		ss.shards[i] = &shard{
+			maxBytes: maxBytes,
+			items:    make(map[string]*entry),
+			policy:   newPolicy(option.Policy, maxBytes),
+		}
+	}
+	return ss
+}
+
+func (ss *ShardedStore) shard(key string) (*shard, uint64) {
+	hash := maphash.String(ss.seed, key)
+	return ss.shards[hash&uint64(len(ss.shards)-1)], hash
+}
+
+// Stats returns the counters of every shard
+func (ss *ShardedStore) Stats() []ShardStats {
+	stats := make([]ShardStats, len(ss.shards))
+	for i, s := range ss.shards {
+		s.mu.Lock()
+		stats[i] = s.stats
+		s.mu.Unlock()
+	}
+	return stats
+}
+
+func (ss *ShardedStore) Get(key string) ([]byte, error) {
+	s, hash := ss.shard(key)
+	now := time.Now().UnixNano()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.items[key]
+	if ok && e.expired(now) {
+		s.remove(e)
+		ok = false
+	}
+	if !ok {
+		s.stats.Misses++
+		s.policy.miss(hash)
+		return nil, nil
+	}
+	s.stats.Hits++
+	s.policy.hit(e)
+	return e.val, nil
+}
+
+func (ss *ShardedStore) Set(key string, val []byte, ttl time.Duration) error {
+	s, hash := ss.shard(key)
+	e := &entry{
+		key:  key,
+		hash: hash,
+		val:  val,
+		size: int64(len(key) + len(val)),
+		freq: 1,
+	}
+	if ttl > 0 {
+		e.expiresAt = time.Now().Add(ttl).UnixNano()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.items[key]; ok {
+		// keep the frequency of the replaced value
+		e.freq = old.freq
+		s.remove(old)
+	}
+	if e.size > s.maxBytes {
+		s.stats.Rejections++
+		return nil
+	}
+
+	s.items[key] = e
+	s.stats.Len++
+	s.stats.Bytes += e.size
+	evicted, rejected := s.policy.add(e)
+	for _, victim := range evicted {
+		s.drop(victim)
+		s.stats.Evictions++
+	}
+	for _, victim := range rejected {
+		s.drop(victim)
+		s.stats.Rejections++
+	}
+	return nil
+}
+
+func (ss *ShardedStore) Delete(key string) error {
+	s, _ := ss.shard(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+	return nil
+}
+
+// exists reports whether key is saved and not expired
+func (ss *ShardedStore) exists(key string) bool {
+	s, _ := ss.shard(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.items[key]
+	return ok && !e.expired(time.Now().UnixNano())
+}
+
+func (ss *ShardedStore) Tag(key string, tags []string, ttl time.Duration) error {
+	ss.tags.add(key, tags, ttl)
+	return nil
+}
+
+func (ss *ShardedStore) PurgeTag(tag string) error {
+	keys := ss.tags.remove(tag)
+	for key := range keys {
+		ss.Delete(key)
+	}
+	return nil
+}
+
+func (ss *ShardedStore) Len() (int64, error) {
+	var n int64
+	for _, stats := range ss.Stats() {
+		n += int64(stats.Len)
+	}
+	return n, nil
+}
+
+func (ss *ShardedStore) SizeBytes() (int64, error) {
+	var size int64
+	for _, stats := range ss.Stats() {
+		size += stats.Bytes
+	}
+	return size, nil
+}
+
+func (ss *ShardedStore) Scan(prefix string, cursor string, count int) ([]string, string, error) {
+	now := time.Now().UnixNano()
+	var keys []string
+	for _, s := range ss.shards {
+		s.mu.Lock()
+		for key, e := range s.items {
+			if !e.expired(now) {
+				keys = append(keys, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+	keys, next := store.ScanKeys(keys, prefix, cursor, count)
+	return keys, next, nil
+}
+
+func (ss *ShardedStore) Clear(prefix string) error {
+	for _, s := range ss.shards {
+		s.mu.Lock()
+		for key, e := range s.items {
+			if strings.HasPrefix(key, prefix) {
+				s.remove(e)
+			}
+		}
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// remove drops e from the shard and its policy
+func (s *shard) remove(e *entry) {
+	s.policy.remove(e)
+	s.drop(e)
+}
+
+// drop drops e, already removed from the policy, from the shard
+func (s *shard) drop(e *entry) {
+	delete(s.items, e.key)
+	s.stats.Len--
+	s.stats.Bytes -= e.size
+}
@@ -0,0 +1,191 @@
+package memorystore
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func shardedStats(ss *ShardedStore) (total ShardStats) {
+	for _, s := range ss.Stats() {
+		total.Len += s.Len
+		total.Bytes += s.Bytes
+		total.Hits += s.Hits
+		total.Misses += s.Misses
+		total.Evictions += s.Evictions
+		total.Rejections += s.Rejections
+	}
+	return total
+}
+
+func TestShardedStore(t *testing.T) {
+	for _, policy := range []Policy{LRU, LFU, TinyLFU} {
+		cache := NewSharded(ShardedStoreOption{MaxBytes: 1 << 20, Policy: policy}).(*ShardedStore)
+		key := "cacheKey"
+		body := []byte("OK")
+
+		t.Run("Get/Set/Delete", func(t *testing.T) {
+			assert.NoError(t, cache.Set(key, body, time.Minute))
+			r, err := cache.Get(key)
+			assert.NoError(t, err)
+			assert.Equal(t, body, r)
+
+			assert.NoError(t, cache.Delete(key))
+			r, err = cache.Get(key)
+			assert.NoError(t, err)
+			assert.Nil(t, r)
+
+			// delete a missing key
+			assert.NoError(t, cache.Delete(key))
+		})
+
+		t.Run("Replace", func(t *testing.T) {
+			assert.NoError(t, cache.Set(key, body, time.Minute))
+			assert.NoError(t, cache.Set(key, []byte("updated"), time.Minute))
+			r, err := cache.Get(key)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("updated"), r)
+
+			stats := shardedStats(cache)
+			assert.Equal(t, 1, stats.Len)
+			assert.Equal(t, int64(len(key)+len("updated")), stats.Bytes)
+			assert.NoError(t, cache.Delete(key))
+		})
+
+		t.Run("Expired", func(t *testing.T) {
+			assert.NoError(t, cache.Set(key, body, time.Millisecond))
+			assert.NoError(t, cache.Set("forever", body, 0))
+			time.Sleep(2 * time.Millisecond)
+
+			r, err := cache.Get(key)
+			assert.NoError(t, err)
+			assert.Nil(t, r)
+			r, err = cache.Get("forever")
+			assert.NoError(t, err)
+			assert.Equal(t, body, r)
+		})
+
+		t.Run("PurgeTag", func(t *testing.T) {
+			assert.NoError(t, cache.Set("a", body, time.Minute))
+			assert.NoError(t, cache.Tag("a", []string{"product:1"}, time.Minute))
+			assert.NoError(t, cache.PurgeTag("product:1"))
+
+			r, err := cache.Get("a")
+			assert.NoError(t, err)
+			assert.Nil(t, r)
+		})
+
+		t.Run("Too large", func(t *testing.T) {
+			small := NewSharded(ShardedStoreOption{MaxBytes: 1024, Shards: 1, Policy: policy}).(*ShardedStore)
+			assert.NoError(t, small.Set(key, body, time.Minute))
+			assert.NoError(t, small.Set(key, make([]byte, 1024), time.Minute))
+
+			// the previous value is dropped
+			r, err := small.Get(key)
+			assert.NoError(t, err)
+			assert.Nil(t, r)
+			assert.Equal(t, uint64(1), small.Stats()[0].Rejections)
+		})
+	}
+}
+
+func TestShardedStoreBudget(t *testing.T) {
+	for _, policy := range []Policy{LRU, LFU, TinyLFU} {
+		cache := NewSharded(ShardedStoreOption{MaxBytes: 64 << 10, Shards: 4, Policy: policy}).(*ShardedStore)
+		for i := range 1000 {
+			assert.NoError(t, cache.Set("key-"+strconv.Itoa(i), make([]byte, 100+i%1000), time.Minute))
+		}
+
+		stats := shardedStats(cache)
+		assert.LessOrEqual(t, stats.Bytes, int64(64<<10))
+		assert.Greater(t, stats.Evictions+stats.Rejections, uint64(0))
+		for _, s := range cache.Stats() {
+			assert.LessOrEqual(t, s.Bytes, int64(16<<10))
+		}
+	}
+}
+
+func TestShardedStorePolicies(t *testing.T) {
+	value := make([]byte, 100-len("key-00"))
+	fill := func(cache *ShardedStore, from, to int) {
+		for i := from; i < to; i++ {
+			key := "key-" + strconv.Itoa(i)
+			if r, _ := cache.Get(key); r == nil {
+				cache.Set(key, value, 0)
+			}
+		}
+	}
+	has := func(cache *ShardedStore, key string) bool {
+		s, _ := cache.shard(key)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		_, ok := s.items[key]
+		return ok
+	}
+
+	t.Run("LRU", func(t *testing.T) {
+		cache := NewSharded(ShardedStoreOption{MaxBytes: 1000, Shards: 1, Policy: LRU}).(*ShardedStore)
+		fill(cache, 10, 20)
+		cache.Get("key-10")
+		fill(cache, 20, 21)
+
+		assert.True(t, has(cache, "key-10"))
+		assert.False(t, has(cache, "key-11"))
+	})
+
+	t.Run("LFU", func(t *testing.T) {
+		cache := NewSharded(ShardedStoreOption{MaxBytes: 1000, Shards: 1, Policy: LFU}).(*ShardedStore)
+		fill(cache, 10, 20)
+		for range 2 {
+			for i := 11; i < 20; i++ {
+				cache.Get("key-" + strconv.Itoa(i))
+			}
+		}
+		cache.Get("key-10")
+		fill(cache, 20, 21)
+
+		// the least frequently used key is evicted first,
+		// even when it was used last
+		assert.False(t, has(cache, "key-10"))
+		assert.True(t, has(cache, "key-11"))
+	})
+
+	t.Run("TinyLFU", func(t *testing.T) {
+		cache := NewSharded(ShardedStoreOption{MaxBytes: 10000, Shards: 1, Policy: TinyLFU}).(*ShardedStore)
+		// popular keys
+		for range 10 {
+			fill(cache, 10, 60)
+		}
+		// a scan of keys used once doesn't evict them
+		fill(cache, 100, 600)
+
+		for i := 10; i < 60; i++ {
+			assert.True(t, has(cache, "key-"+strconv.Itoa(i)))
+		}
+		assert.Greater(t, shardedStats(cache).Rejections, uint64(0))
+	})
+}
+
+func TestShardedStoreConcurrency(t *testing.T) {
+	cache := NewSharded(ShardedStoreOption{MaxBytes: 16 << 10, Policy: TinyLFU})
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 1000 {
+				key := "key-" + strconv.Itoa((i*j)%300)
+				if r, _ := cache.Get(key); r == nil {
+					cache.Set(key, []byte(key), time.Minute)
+				}
+				if j%10 == 0 {
+					cache.Delete(key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
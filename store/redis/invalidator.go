@@ -0,0 +1,82 @@
+package redisstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sdvcrx/echo-cache/store"
+)
+
+// Channel of the invalidation messages when none is given
+const DefaultInvalidationChannel = "echo-cache:invalidate"
+
+// Invalidator broadcasts the invalidated keys on a Redis channel. Messages
+// are prefixed with the ID of the instance, so it ignores its own.
+// Keys published while an instance is disconnected are not received.
+type Invalidator struct {
+	client  redis.UniversalClient
+	channel string
+	id      string
+
+	mu      sync.Mutex
+	pubsubs []*redis.PubSub
+}
+
+func NewInvalidator(opt *redis.UniversalOptions, channel string) *Invalidator {
+	if channel == "" {
+		channel = DefaultInvalidationChannel
+	}
+	var id [8]byte
+	rand.Read(id[:])
+	return &Invalidator{
+		client:  redis.NewUniversalClient(opt),
+		channel: channel,
+		id:      hex.EncodeToString(id[:]),
+	}
+}
+
+var _ store.Invalidator = (*Invalidator)(nil)
+
+func (inv *Invalidator) Publish(key string) error {
+	return inv.client.Publish(context.Background(), inv.channel, inv.id+":"+key).Err()
+}
+
+// Subscribe calls fn in a goroutine for each key published by the other instances
+func (inv *Invalidator) Subscribe(fn func(key string)) error {
+	ctx := context.Background()
+	pubsub := inv.client.Subscribe(ctx, inv.channel)
+	// wait for the subscription to be confirmed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return err
+	}
+
+	inv.mu.Lock()
+	inv.pubsubs = append(inv.pubsubs, pubsub)
+	inv.mu.Unlock()
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			id, key, ok := strings.Cut(msg.Payload, ":")
+			if ok && id != inv.id {
+				fn(key)
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops the subscriptions and closes the client
+func (inv *Invalidator) Close() error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	for _, pubsub := range inv.pubsubs {
+		pubsub.Close()
+	}
+	inv.pubsubs = nil
+	return inv.client.Close()
+}
@@ -0,0 +1,67 @@
+package redisstore
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// keyRecorder records the keys received by an invalidator
+type keyRecorder struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (r *keyRecorder) record(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = append(r.keys, key)
+}
+
+func (r *keyRecorder) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.keys...)
+}
+
+func TestInvalidator(t *testing.T) {
+	mr := miniredis.RunT(t)
+	opt := &redis.UniversalOptions{Addrs: []string{mr.Addr()}}
+
+	first := NewInvalidator(opt, "")
+	defer first.Close()
+	second := NewInvalidator(opt, "")
+	defer second.Close()
+	other := NewInvalidator(opt, "other")
+	defer other.Close()
+
+	var firstKeys, secondKeys, otherKeys keyRecorder
+	assert.NoError(t, first.Subscribe(firstKeys.record))
+	assert.NoError(t, second.Subscribe(secondKeys.record))
+	assert.NoError(t, other.Subscribe(otherKeys.record))
+
+	assert.NoError(t, first.Publish("cache-GET-/posts"))
+	assert.NoError(t, first.Publish("key:with:colons"))
+
+	assert.Eventually(t, func() bool {
+		return len(secondKeys.received()) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"cache-GET-/posts", "key:with:colons"}, secondKeys.received())
+
+	// own messages and other channels are ignored
+	assert.Empty(t, firstKeys.received())
+	assert.Empty(t, otherKeys.received())
+}
+
+func TestInvalidatorSubscribeError(t *testing.T) {
+	mr := miniredis.RunT(t)
+	inv := NewInvalidator(&redis.UniversalOptions{Addrs: []string{mr.Addr()}}, "")
+	defer inv.Close()
+	mr.Close()
+
+	assert.Error(t, inv.Subscribe(func(key string) {}))
+}
@@ -1,8 +1,14 @@
 package redisstore
 
 import (
+	"bufio"
 	"context"
 	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -20,9 +26,48 @@ func New(opt *redis.UniversalOptions) store.Store {
 }
 
 var _ store.Store = (*RedisStore)(nil)
+var _ store.TagStore = (*RedisStore)(nil)
+var _ store.ContextStore = (*RedisStore)(nil)
+var _ store.StreamStore = (*RedisStore)(nil)
+var _ store.ContextTagStore = (*RedisStore)(nil)
+var _ store.ContextStreamStore = (*RedisStore)(nil)
+var _ store.Inspector = (*RedisStore)(nil)
+var _ store.PrefixSizer = (*RedisStore)(nil)
+var _ store.Clearer = (*RedisStore)(nil)
+var _ store.BatchStore = (*RedisStore)(nil)
+
+// Size of the chunks written and read by the stream methods
+const streamChunkSize = 256 * 1024
+
+// Number of keys asked to each SCAN of Clear
+const clearBatchSize = 1000
+
+// Prefix of the sets which hold the keys of a tag
+const tagKeyPrefix = "echo-cache:tag:"
+
+// tagScript adds ARGV[1] to the set KEYS[1] and extends the set
+// expiration to ARGV[2] milliseconds, zero means never expire
+var tagScript = redis.NewScript(`
+local existed = redis.call('EXISTS', KEYS[1])
+redis.call('SADD', KEYS[1], ARGV[1])
+local ttl = tonumber(ARGV[2])
+if ttl <= 0 then
+	redis.call('PERSIST', KEYS[1])
+	return 1
+end
+local current = redis.call('PTTL', KEYS[1])
+if existed == 0 or (current >= 0 and current < ttl) then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return 1
+`)
 
 func (ra *RedisStore) Get(key string) ([]byte, error) {
-	val, err := ra.client.Get(context.Background(), key).Bytes()
+	return ra.GetContext(context.Background(), key)
+}
+
+func (ra *RedisStore) GetContext(ctx context.Context, key string) ([]byte, error) {
+	val, err := ra.client.Get(ctx, key).Bytes()
 	if err != nil {
 		// no data
 		if errors.Is(err, redis.Nil) {
@@ -34,6 +79,449 @@ func (ra *RedisStore) Get(key string) ([]byte, error) {
 }
 
 func (ra *RedisStore) Set(key string, val []byte, ttl time.Duration) error {
-	_, err := ra.client.Set(context.Background(), key, val, ttl).Result()
+	return ra.SetContext(context.Background(), key, val, ttl)
+}
+
+func (ra *RedisStore) SetContext(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	_, err := ra.client.Set(ctx, key, val, ttl).Result()
 	return err
 }
+
+func (ra *RedisStore) Delete(key string) error {
+	return ra.DeleteContext(context.Background(), key)
+}
+
+func (ra *RedisStore) DeleteContext(ctx context.Context, key string) error {
+	return ra.client.Del(ctx, key).Err()
+}
+
+func (ra *RedisStore) Tag(key string, tags []string, ttl time.Duration) error {
+	return ra.TagContext(context.Background(), key, tags, ttl)
+}
+
+func (ra *RedisStore) TagContext(ctx context.Context, key string, tags []string, ttl time.Duration) error {
+	// run once per tag, tag sets may live in different cluster slots
+	for _, tag := range tags {
+		err := tagScript.Run(ctx, ra.client, []string{tagKeyPrefix + tag}, key, ttl.Milliseconds()).Err()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ra *RedisStore) PurgeTag(tag string) error {
+	ctx := context.Background()
+	tagKey := tagKeyPrefix + tag
+	keys, err := ra.client.SMembers(ctx, tagKey).Result()
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+
+	pipe := ra.client.Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, key)
+	}
+	// keep the keys tagged since SMEMBERS
+	pipe.SRem(ctx, tagKey, keys)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Len returns the number of keys of the database,
+// including the tag sets and the keys not written by the store
+func (ra *RedisStore) Len() (int64, error) {
+	return ra.client.DBSize(context.Background()).Result()
+}
+
+// SizeBytes returns the memory used by the dataset of the server as
+// reported by `INFO memory`, which includes the keys not written by the
+// store, see SizeBytesPrefix. It is not supported by cluster clients.
+func (ra *RedisStore) SizeBytes() (int64, error) {
+	if _, ok := ra.client.(*redis.ClusterClient); ok {
+		return 0, store.ErrNotSupported
+	}
+	info, err := ra.client.Info(context.Background(), "memory").Result()
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(info, "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "used_memory_dataset:"); ok {
+			return strconv.ParseInt(v, 10, 64)
+		}
+	}
+	return 0, errors.New("redisstore: used_memory_dataset missing from INFO")
+}
+
+// SizeBytesPrefix sums the `MEMORY USAGE` of the keys starting with prefix,
+// found with SCAN on every master node with cluster clients
+func (ra *RedisStore) SizeBytesPrefix(prefix string) (int64, error) {
+	ctx := context.Background()
+	if cluster, ok := ra.client.(*redis.ClusterClient); ok {
+		var total atomic.Int64
+		err := cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			size, err := prefixSize(ctx, client, prefix)
+			total.Add(size)
+			return err
+		})
+		return total.Load(), err
+	}
+	return prefixSize(ctx, ra.client, prefix)
+}
+
+func prefixSize(ctx context.Context, client redis.UniversalClient, prefix string) (int64, error) {
+	match := globEscape(prefix) + "*"
+	var size int64
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(ctx, cursor, match, clearBatchSize).Result()
+		if err != nil {
+			return 0, err
+		}
+		if len(keys) > 0 {
+			pipe := client.Pipeline()
+			usages := make([]*redis.IntCmd, len(keys))
+			for i, key := range keys {
+				usages[i] = pipe.MemoryUsage(ctx, key)
+			}
+			// a key removed since SCAN fails with redis.Nil
+			if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+				return 0, err
+			}
+			for _, usage := range usages {
+				n, err := usage.Result()
+				if err != nil && !errors.Is(err, redis.Nil) {
+					return 0, err
+				}
+				size += n
+			}
+		}
+		if next == 0 {
+			return size, nil
+		}
+		cursor = next
+	}
+}
+
+// Scan iterates the keys with SCAN, the tag sets are skipped.
+// It is not supported by cluster clients.
+func (ra *RedisStore) Scan(prefix string, cursor string, count int) ([]string, string, error) {
+	if _, ok := ra.client.(*redis.ClusterClient); ok {
+		return nil, "", store.ErrNotSupported
+	}
+	var position uint64
+	if cursor != "" {
+		var err error
+		if position, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return nil, "", err
+		}
+	}
+
+	keys, position, err := ra.client.Scan(context.Background(), position, globEscape(prefix)+"*", int64(count)).Result()
+	if err != nil {
+		return nil, "", err
+	}
+	keys = slices.DeleteFunc(keys, func(key string) bool {
+		return strings.HasPrefix(key, tagKeyPrefix)
+	})
+	if position == 0 {
+		return keys, "", nil
+	}
+	return keys, strconv.FormatUint(position, 10), nil
+}
+
+// Clear scans the keys starting with prefix and unlinks them by batches,
+// on every master node with cluster clients
+func (ra *RedisStore) Clear(prefix string) error {
+	ctx := context.Background()
+	if cluster, ok := ra.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return unlinkPrefix(ctx, client, prefix)
+		})
+	}
+	return unlinkPrefix(ctx, ra.client, prefix)
+}
+
+func unlinkPrefix(ctx context.Context, client redis.UniversalClient, prefix string) error {
+	match := globEscape(prefix) + "*"
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(ctx, cursor, match, clearBatchSize).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			// one key per command, the keys may live in different cluster slots
+			pipe := client.Pipeline()
+			for _, key := range keys {
+				pipe.Unlink(ctx, key)
+			}
+			if _, err := pipe.Exec(ctx); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// globEscape escapes the special characters of MATCH patterns in s
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, ch := range s {
+		switch ch {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
+
+// GetMulti reads keys with MGET, or with a pipeline of GET for
+// cluster clients since the keys may live in different slots
+func (ra *RedisStore) GetMulti(keys []string) (map[string][]byte, error) {
+	ctx := context.Background()
+	values := make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	if _, ok := ra.client.(*redis.ClusterClient); ok {
+		pipe := ra.client.Pipeline()
+		cmds := make([]*redis.StringCmd, len(keys))
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, key)
+		}
+		// the errors are checked per command, missing keys fail with redis.Nil
+		pipe.Exec(ctx)
+		for i, cmd := range cmds {
+			val, err := cmd.Bytes()
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			values[keys[i]] = val
+		}
+		return values, nil
+	}
+
+	results, err := ra.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range results {
+		// nil for missing keys
+		if val, ok := result.(string); ok {
+			values[keys[i]] = []byte(val)
+		}
+	}
+	return values, nil
+}
+
+// SetMulti sets items with a pipeline, so they are saved with ttl
+func (ra *RedisStore) SetMulti(items map[string][]byte, ttl time.Duration) error {
+	ctx := context.Background()
+	pipe := ra.client.Pipeline()
+	for key, val := range items {
+		pipe.Set(ctx, key, val, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DeleteMulti deletes keys with a pipeline, one key per command
+// since the keys may live in different cluster slots
+func (ra *RedisStore) DeleteMulti(keys []string) error {
+	ctx := context.Background()
+	pipe := ra.client.Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, key)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SetStream appends the chunks read from r to a temporary key, which is
+// renamed to key at the end. The temporary key shares the hash slot of key
+// and expires with ttl when the writer goes away, so concurrent streams
+// of the same key are not supported.
+func (ra *RedisStore) SetStream(key string, r io.Reader, ttl time.Duration) error {
+	return ra.SetStreamContext(context.Background(), key, r, ttl)
+}
+
+func (ra *RedisStore) SetStreamContext(ctx context.Context, key string, r io.Reader, ttl time.Duration) error {
+	tmpKey := streamTmpKey(key)
+	buf := make([]byte, streamChunkSize)
+	written := false
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			var werr error
+			if written {
+				werr = ra.client.Append(ctx, tmpKey, string(buf[:n])).Err()
+			} else {
+				werr = ra.client.Set(ctx, tmpKey, buf[:n], ttl).Err()
+				written = true
+			}
+			if werr != nil {
+				ra.client.Del(context.WithoutCancel(ctx), tmpKey)
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			ra.client.Del(context.WithoutCancel(ctx), tmpKey)
+			return err
+		}
+	}
+
+	if !written {
+		return ra.client.Set(ctx, key, "", ttl).Err()
+	}
+	return ra.client.Rename(ctx, tmpKey, key).Err()
+}
+
+// streamTmpKey returns the temporary key of a stream of key,
+// which lives in the same cluster slot to be renamed to key
+func streamTmpKey(key string) string {
+	tag := hashTag(key)
+	if tag != key {
+		// appending keeps the hash tag of key
+		return key + ".tmp"
+	}
+	if key != "" && !strings.Contains(key, "}") {
+		return "{" + key + "}.tmp"
+	}
+	// a hash tag can't hold '}', use another tag of the slot
+	return "{" + slotTag(keySlot(key)) + "}" + key + ".tmp"
+}
+
+// hashTag returns the part of key hashed to find its cluster slot,
+// the first non-empty {...} section or the whole key
+func hashTag(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+	return key
+}
+
+// Number of hash slots of a redis cluster
+const clusterSlots = 16384
+
+// keySlot returns the cluster slot of key
+func keySlot(key string) uint16 {
+	return crc16(hashTag(key)) % clusterSlots
+}
+
+// slotTag returns the shortest number whose slot is slot
+func slotTag(slot uint16) string {
+	for i := 0; ; i++ {
+		tag := strconv.Itoa(i)
+		if crc16(tag)%clusterSlots == slot {
+			return tag
+		}
+	}
+}
+
+// crc16 is the CRC-16/XMODEM checksum used by redis cluster
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// GetStream reads the value of key by ranges of `streamChunkSize`.
+// The reader fails when the key is deleted or replaced while reading.
+func (ra *RedisStore) GetStream(key string) (io.ReadCloser, error) {
+	return ra.GetStreamContext(context.Background(), key)
+}
+
+func (ra *RedisStore) GetStreamContext(ctx context.Context, key string) (io.ReadCloser, error) {
+	pipe := ra.client.Pipeline()
+	exists := pipe.Exists(ctx, key)
+	size := pipe.StrLen(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	if exists.Val() == 0 {
+		return nil, nil
+	}
+
+	r := &rangeReader{ctx: ctx, client: ra.client, key: key, size: size.Val()}
+	return &streamReader{bufio.NewReaderSize(r, streamChunkSize), r}, nil
+}
+
+// streamReader buffers the chunks of a rangeReader, it implements io.Seeker
+type streamReader struct {
+	*bufio.Reader
+	r *rangeReader
+}
+
+func (sr *streamReader) Seek(offset int64, whence int) (int64, error) {
+	// the buffered chunk is ahead of the reader
+	pos := sr.r.offset - int64(sr.Buffered())
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += pos
+	case io.SeekEnd:
+		offset += sr.r.size
+	default:
+		return 0, errors.New("redisstore: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("redisstore: negative position")
+	}
+	sr.r.offset = offset
+	sr.Reset(sr.r)
+	return offset, nil
+}
+
+func (sr *streamReader) Close() error {
+	return nil
+}
+
+// rangeReader reads a string value with GETRANGE
+type rangeReader struct {
+	ctx    context.Context
+	client redis.UniversalClient
+	key    string
+	offset int64
+	size   int64
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	end := min(r.offset+int64(len(p)), r.size) - 1
+	b, err := r.client.GetRange(r.ctx, r.key, r.offset, end).Bytes()
+	if err != nil {
+		return 0, err
+	}
+	if len(b) == 0 {
+		// the key is gone or shorter than expected
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, b)
+	r.offset += int64(n)
+	return n, nil
+}
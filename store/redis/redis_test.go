@@ -1,10 +1,15 @@
 package redisstore
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redismock/v9"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
@@ -57,6 +62,313 @@ func TestCacheRedisStore(t *testing.T) {
 		err := ra.Set(key, valByte, 0)
 		assert.ErrorIs(t, err, redis.ErrClosed)
 	})
+
+	t.Run("Delete success", func(t *testing.T) {
+		mock.ExpectDel(key).SetVal(1)
+		err := ra.Delete(key)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Delete error", func(t *testing.T) {
+		mock.ExpectDel(key).SetErr(redis.ErrClosed)
+		err := ra.Delete(key)
+		assert.ErrorIs(t, err, redis.ErrClosed)
+	})
+}
+
+func TestRedisStoreTags(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ra := &RedisStore{
+		client: redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+	}
+	body := []byte("OK")
+
+	t.Run("PurgeTag", func(t *testing.T) {
+		for _, key := range []string{"a", "b", "c"} {
+			assert.NoError(t, ra.Set(key, body, time.Minute))
+		}
+		assert.NoError(t, ra.Tag("a", []string{"product:1", "product:2"}, time.Minute))
+		assert.NoError(t, ra.Tag("b", []string{"product:1"}, time.Minute))
+
+		assert.NoError(t, ra.PurgeTag("product:1"))
+		for key, expected := range map[string][]byte{"a": nil, "b": nil, "c": body} {
+			r, err := ra.Get(key)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, r)
+		}
+		assert.False(t, mr.Exists(tagKeyPrefix+"product:1"))
+
+		// purge a missing tag
+		assert.NoError(t, ra.PurgeTag("product:3"))
+	})
+
+	t.Run("Tag expiration", func(t *testing.T) {
+		tagKey := tagKeyPrefix + "ttl"
+		assert.NoError(t, ra.Tag("a", []string{"ttl"}, time.Minute))
+		assert.Equal(t, time.Minute, mr.TTL(tagKey))
+
+		// keep the longest expiration
+		assert.NoError(t, ra.Tag("b", []string{"ttl"}, time.Second))
+		assert.Equal(t, time.Minute, mr.TTL(tagKey))
+		assert.NoError(t, ra.Tag("c", []string{"ttl"}, time.Hour))
+		assert.Equal(t, time.Hour, mr.TTL(tagKey))
+
+		// expire with the keys
+		mr.FastForward(time.Hour)
+		assert.False(t, mr.Exists(tagKey))
+
+		// never expire
+		assert.NoError(t, ra.Tag("d", []string{"ttl"}, 0))
+		assert.NoError(t, ra.Tag("e", []string{"ttl"}, time.Second))
+		assert.Equal(t, time.Duration(0), mr.TTL(tagKey))
+	})
+}
+
+func TestRedisStoreContext(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ra := &RedisStore{
+		client: redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+	}
+	key := "cacheKey"
+	body := []byte("OK")
+
+	t.Run("Get/Set/Delete", func(t *testing.T) {
+		ctx := context.Background()
+		assert.NoError(t, ra.SetContext(ctx, key, body, time.Minute))
+
+		r, err := ra.GetContext(ctx, key)
+		assert.NoError(t, err)
+		assert.Equal(t, body, r)
+
+		assert.NoError(t, ra.DeleteContext(ctx, key))
+		assert.False(t, mr.Exists(key))
+	})
+
+	t.Run("Context done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := ra.GetContext(ctx, key)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorIs(t, ra.SetContext(ctx, key, body, 0), context.Canceled)
+		assert.ErrorIs(t, ra.DeleteContext(ctx, key), context.Canceled)
+		assert.ErrorIs(t, ra.TagContext(ctx, key, []string{"tag"}, 0), context.Canceled)
+		_, err = ra.GetStreamContext(ctx, key)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorIs(t, ra.SetStreamContext(ctx, "stream", bytes.NewReader(body), 0), context.Canceled)
+		assert.False(t, mr.Exists("stream"))
+	})
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestRedisStoreStream(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ra := &RedisStore{
+		client: redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+	}
+	key := "bodyKey"
+	// several chunks and a partial one
+	body := bytes.Repeat([]byte("0123456789"), streamChunkSize/4)
+
+	t.Run("SetStream/GetStream", func(t *testing.T) {
+		assert.NoError(t, ra.SetStream(key, bytes.NewReader(body), time.Minute))
+		assert.False(t, mr.Exists("{"+key+"}.tmp"))
+		assert.Equal(t, time.Minute, mr.TTL(key))
+
+		r, err := ra.GetStream(key)
+		assert.NoError(t, err)
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, body, b)
+	})
+
+	t.Run("Seek", func(t *testing.T) {
+		r, err := ra.GetStream(key)
+		assert.NoError(t, err)
+		defer r.Close()
+		rs, ok := r.(io.ReadSeeker)
+		assert.True(t, ok)
+
+		size, err := rs.Seek(0, io.SeekEnd)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(body)), size)
+		offset := int64(streamChunkSize + 5)
+		_, err = rs.Seek(offset, io.SeekStart)
+		assert.NoError(t, err)
+		b := make([]byte, 3)
+		_, err = io.ReadFull(rs, b)
+		assert.NoError(t, err)
+		assert.Equal(t, body[offset:offset+3], b)
+
+		pos, err := rs.Seek(-1, io.SeekCurrent)
+		assert.NoError(t, err)
+		assert.Equal(t, offset+2, pos)
+	})
+
+	t.Run("Empty value", func(t *testing.T) {
+		assert.NoError(t, ra.SetStream("empty", bytes.NewReader(nil), time.Minute))
+
+		r, err := ra.GetStream("empty")
+		assert.NoError(t, err)
+		b, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Empty(t, b)
+	})
+
+	t.Run("Key without hash tag", func(t *testing.T) {
+		key := "/search?q=}"
+		assert.NoError(t, ra.SetStream(key, bytes.NewReader(body), time.Minute))
+		assert.False(t, mr.Exists(streamTmpKey(key)))
+
+		r, err := ra.GetStream(key)
+		assert.NoError(t, err)
+		b, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, body, b)
+	})
+
+	t.Run("Missing key", func(t *testing.T) {
+		r, err := ra.GetStream("missing")
+		assert.NoError(t, err)
+		assert.Nil(t, r)
+	})
+
+	t.Run("Reader fails", func(t *testing.T) {
+		r := io.MultiReader(bytes.NewReader(body), failingReader{})
+		assert.Error(t, ra.SetStream("failed", r, time.Minute))
+		assert.False(t, mr.Exists("failed"))
+		assert.False(t, mr.Exists("{failed}.tmp"))
+	})
+}
+
+func TestStreamTmpKey(t *testing.T) {
+	assert.Equal(t, uint16(0x31C3), crc16("123456789"))
+	assert.Equal(t, keySlot("{user1000}.following"), keySlot("{user1000}.followers"))
+
+	keys := []string{
+		"", "key", "{tag}key", "key{tag}", "a{b", "a}b", "a{}b", "{}key", "}{a}",
+		"echo-cache-GET-/search?q={id}", "echo-cache-GET-/search?q=}",
+	}
+	for _, key := range keys {
+		tmpKey := streamTmpKey(key)
+		assert.NotEqual(t, key, tmpKey)
+		assert.Equal(t, keySlot(key), keySlot(tmpKey), key)
+	}
+	assert.NotEqual(t, streamTmpKey("{tag}a"), streamTmpKey("{tag}b"))
+	assert.NotEqual(t, streamTmpKey("a}"), streamTmpKey("b}"))
+}
+
+func TestRedisStoreInspector(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ra := &RedisStore{
+		client: redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+	}
+	for _, key := range []string{"a-1", "b-1", "b-2", "b*3"} {
+		assert.NoError(t, ra.Set(key, []byte("OK"), time.Minute))
+	}
+	assert.NoError(t, ra.Tag("b-1", []string{"b-"}, time.Minute))
+
+	t.Run("Len", func(t *testing.T) {
+		n, err := ra.Len()
+		assert.NoError(t, err)
+		// with the tag set
+		assert.Equal(t, int64(5), n)
+	})
+
+	t.Run("Scan", func(t *testing.T) {
+		var keys []string
+		cursor := ""
+		for {
+			page, next, err := ra.Scan("b-", cursor, 1)
+			assert.NoError(t, err)
+			keys = append(keys, page...)
+			if cursor = next; cursor == "" {
+				break
+			}
+		}
+		assert.ElementsMatch(t, []string{"b-1", "b-2"}, keys)
+
+		keys, _, err := ra.Scan("b*", "", 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"b*3"}, keys)
+	})
+
+	t.Run("SizeBytesPrefix", func(t *testing.T) {
+		size, err := ra.SizeBytesPrefix("b-")
+		assert.NoError(t, err)
+		assert.Greater(t, size, int64(0))
+
+		all, err := ra.SizeBytesPrefix("")
+		assert.NoError(t, err)
+		assert.Greater(t, all, size)
+
+		size, err = ra.SizeBytesPrefix("missing-")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), size)
+	})
+
+	t.Run("SizeBytes", func(t *testing.T) {
+		db, mock := redismock.NewClientMock()
+		ra := &RedisStore{client: db}
+		mock.ExpectInfo("memory").SetVal("# Memory\r\nused_memory:2048\r\nused_memory_dataset:1024\r\n")
+
+		size, err := ra.SizeBytes()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1024), size)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRedisStoreClear(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ra := &RedisStore{
+		client: redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+	}
+	for i := range 2500 {
+		assert.NoError(t, ra.Set("app1-GET-/"+strconv.Itoa(i), []byte("OK"), time.Minute))
+	}
+	assert.NoError(t, ra.Set("app2-GET-/", []byte("OK"), time.Minute))
+	assert.NoError(t, ra.Set("app1*", []byte("OK"), time.Minute))
+
+	assert.NoError(t, ra.Clear("app1-"))
+	assert.Equal(t, []string{"app1*", "app2-GET-/"}, mr.Keys())
+
+	assert.NoError(t, ra.Clear(""))
+	assert.Empty(t, mr.Keys())
+}
+
+func TestRedisStoreMulti(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ra := &RedisStore{
+		client: redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+	}
+	items := map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": {}}
+
+	assert.NoError(t, ra.SetMulti(items, time.Minute))
+	assert.Equal(t, time.Minute, mr.TTL("a"))
+
+	values, err := ra.GetMulti([]string{"a", "b", "c", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, items, values)
+
+	assert.NoError(t, ra.DeleteMulti([]string{"a", "c", "missing"}))
+	values, err = ra.GetMulti([]string{"a", "b", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"b": []byte("2")}, values)
+
+	t.Run("No keys", func(t *testing.T) {
+		values, err := ra.GetMulti(nil)
+		assert.NoError(t, err)
+		assert.Empty(t, values)
+		assert.NoError(t, ra.SetMulti(nil, time.Minute))
+		assert.NoError(t, ra.DeleteMulti(nil))
+	})
 }
 
 func TestRedisStoreWithRealServer(t *testing.T) {
@@ -82,6 +394,15 @@ func TestRedisStoreWithRealServer(t *testing.T) {
 		assert.NotNil(t, resp)
 	})
 
+	t.Run("Delete", func(t *testing.T) {
+		err := ra.Delete(key)
+		assert.NoError(t, err)
+
+		resp, err := ra.Get(key)
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+	})
+
 	t.Run("Set with TTL", func(t *testing.T) {
 		ttl := time.Second
 		err := ra.Set(key, body, ttl)
@@ -6,6 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -32,10 +35,29 @@ type SQLStore struct {
 
 	stmtGet          *sql.Stmt
 	stmtSet          *sql.Stmt
+	stmtDelete       *sql.Stmt
 	stmtCleanExpired *sql.Stmt
+
+	stmtTag             *sql.Stmt
+	stmtPurgeTagKeys    *sql.Stmt
+	stmtPurgeTag        *sql.Stmt
+	stmtCleanExpiredTag *sql.Stmt
+
+	stmtLen  *sql.Stmt
+	stmtSize *sql.Stmt
+	stmtScan *sql.Stmt
+
+	stmtClear    *sql.Stmt
+	stmtClearTag *sql.Stmt
 }
 
 var _ store.Store = (*SQLStore)(nil)
+var _ store.TagStore = (*SQLStore)(nil)
+var _ store.ContextTagStore = (*SQLStore)(nil)
+var _ store.ContextStore = (*SQLStore)(nil)
+var _ store.Inspector = (*SQLStore)(nil)
+var _ store.Clearer = (*SQLStore)(nil)
+var _ store.BatchStore = (*SQLStore)(nil)
 
 var DefaultSQLStoreOption = SQLStoreOption{
 	Ctx:       context.Background(),
@@ -43,6 +65,15 @@ var DefaultSQLStoreOption = SQLStoreOption{
 	DBName:    SQLite,
 }
 
+// Page size of Scan when count is not positive
+const DefaultScanCount = 100
+
+// Number of keys of each statement of GetMulti, SetMulti and DeleteMulti,
+// within the limit of bind variables of SQLite
+const batchSize = 100
+
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
 func New(option SQLStoreOption) store.Store {
 	sqlStore := &SQLStore{
 		SQLStoreOption: DefaultSQLStoreOption,
@@ -77,6 +108,23 @@ expired_at %s
 	if err != nil {
 		log.Fatalln(err)
 	}
+
+	query = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+tag %s,
+cache_key %s,
+expired_at %s,
+PRIMARY KEY (tag, cache_key)
+)`, sa.tagTableName(), sa.dialect.TypeText, sa.dialect.TypeText, sa.dialect.TypeBigInt)
+
+	_, err = sa.DB.ExecContext(sa.Ctx, query)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// The table of the tag index
+func (sa *SQLStore) tagTableName() string {
+	return sa.TableName + "_tags"
 }
 
 func (sa *SQLStore) prepareGet(ctx context.Context) *sql.Stmt {
@@ -108,6 +156,15 @@ UPDATE value = VALUES(value), expired_at = VALUES(expired_at)`
 	return must(sa.DB.PrepareContext(ctx, query))
 }
 
+func (sa *SQLStore) prepareDelete(ctx context.Context) *sql.Stmt {
+	placeholder := "?"
+	if sa.DBName == PostgreSQL {
+		placeholder = "$1"
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE cache_key = %s", sa.TableName, placeholder)
+	return must(sa.DB.PrepareContext(ctx, query))
+}
+
 func (sa *SQLStore) prepareCleanExpired(ctx context.Context) *sql.Stmt {
 	placeholder := "?"
 	if sa.DBName == PostgreSQL {
@@ -117,6 +174,114 @@ func (sa *SQLStore) prepareCleanExpired(ctx context.Context) *sql.Stmt {
 	return must(sa.DB.PrepareContext(ctx, query))
 }
 
+func (sa *SQLStore) prepareTag(ctx context.Context) *sql.Stmt {
+	placeholder := "?, ?, ?"
+	onConflict := `ON CONFLICT (tag, cache_key) DO UPDATE
+SET expired_at = EXCLUDED.expired_at`
+
+	if sa.DBName == PostgreSQL {
+		placeholder = "$1, $2, $3"
+	} else if sa.DBName == MySQL {
+		onConflict = `ON DUPLICATE KEY
+UPDATE expired_at = VALUES(expired_at)`
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %s (tag, cache_key, expired_at) VALUES (%s) %s`, sa.tagTableName(), placeholder, onConflict)
+	return must(sa.DB.PrepareContext(ctx, query))
+}
+
+func (sa *SQLStore) preparePurgeTagKeys(ctx context.Context) *sql.Stmt {
+	placeholder := "?"
+	if sa.DBName == PostgreSQL {
+		placeholder = "$1"
+	}
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE cache_key IN (SELECT cache_key FROM %s WHERE tag = %s)",
+		sa.TableName, sa.tagTableName(), placeholder,
+	)
+	return must(sa.DB.PrepareContext(ctx, query))
+}
+
+func (sa *SQLStore) preparePurgeTag(ctx context.Context) *sql.Stmt {
+	placeholder := "?"
+	if sa.DBName == PostgreSQL {
+		placeholder = "$1"
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE tag = %s", sa.tagTableName(), placeholder)
+	return must(sa.DB.PrepareContext(ctx, query))
+}
+
+func (sa *SQLStore) prepareCleanExpiredTag(ctx context.Context) *sql.Stmt {
+	placeholder := "?"
+	if sa.DBName == PostgreSQL {
+		placeholder = "$1"
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE expired_at < %s", sa.tagTableName(), placeholder)
+	return must(sa.DB.PrepareContext(ctx, query))
+}
+
+func (sa *SQLStore) prepareLen(ctx context.Context) *sql.Stmt {
+	placeholder := "?"
+	if sa.DBName == PostgreSQL {
+		placeholder = "$1"
+	}
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE expired_at > %s", sa.TableName, placeholder)
+	return must(sa.DB.PrepareContext(ctx, query))
+}
+
+func (sa *SQLStore) prepareSize(ctx context.Context) *sql.Stmt {
+	placeholder := "?"
+	if sa.DBName == PostgreSQL {
+		placeholder = "$1"
+	}
+	size := fmt.Sprintf(sa.dialect.OctetLength, "cache_key") + " + " + fmt.Sprintf(sa.dialect.OctetLength, "value")
+	query := fmt.Sprintf(
+		"SELECT COALESCE(SUM(%s), 0) FROM %s WHERE expired_at > %s",
+		size, sa.TableName, placeholder,
+	)
+	return must(sa.DB.PrepareContext(ctx, query))
+}
+
+func (sa *SQLStore) prepareScan(ctx context.Context) *sql.Stmt {
+	whereClause := "cache_key LIKE ? ESCAPE '!' AND cache_key > ? AND expired_at > ?"
+	limit := "?"
+	if sa.DBName == PostgreSQL {
+		whereClause = "cache_key LIKE $1 ESCAPE '!' AND cache_key > $2 AND expired_at > $3"
+		limit = "$4"
+	}
+	query := fmt.Sprintf(
+		"SELECT cache_key FROM %s WHERE %s ORDER BY cache_key LIMIT %s",
+		sa.TableName, whereClause, limit,
+	)
+	return must(sa.DB.PrepareContext(ctx, query))
+}
+
+// prepareClear deletes the rows of table whose key starts with a prefix
+func (sa *SQLStore) prepareClear(ctx context.Context, table string) *sql.Stmt {
+	whereClause := "cache_key LIKE ? ESCAPE '!'"
+	if sa.DBName == PostgreSQL {
+		whereClause = "cache_key LIKE $1 ESCAPE '!'"
+	}
+	if sa.dialect.PrefixCheck != "" {
+		whereClause += " AND " + sa.dialect.PrefixCheck
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", table, whereClause)
+	return must(sa.DB.PrepareContext(ctx, query))
+}
+
+// placeholders returns n bind variables, numbered from start in PostgreSQL
+func (sa *SQLStore) placeholders(n, start int) string {
+	vars := make([]string, n)
+	for i := range vars {
+		if sa.DBName == PostgreSQL {
+			vars[i] = fmt.Sprintf("$%d", start+i)
+		} else {
+			vars[i] = "?"
+		}
+	}
+	return strings.Join(vars, ", ")
+}
+
 func (sa *SQLStore) init() {
 	if sa.TableName == "" {
 		log.Fatalln("echo-cache sqlstore: tableName cannot be empty")
@@ -134,7 +299,17 @@ func (sa *SQLStore) init() {
 	sa.createTable()
 	sa.stmtGet = sa.prepareGet(sa.Ctx)
 	sa.stmtSet = sa.prepareSet(sa.Ctx)
+	sa.stmtDelete = sa.prepareDelete(sa.Ctx)
 	sa.stmtCleanExpired = sa.prepareCleanExpired(sa.Ctx)
+	sa.stmtTag = sa.prepareTag(sa.Ctx)
+	sa.stmtPurgeTagKeys = sa.preparePurgeTagKeys(sa.Ctx)
+	sa.stmtPurgeTag = sa.preparePurgeTag(sa.Ctx)
+	sa.stmtCleanExpiredTag = sa.prepareCleanExpiredTag(sa.Ctx)
+	sa.stmtLen = sa.prepareLen(sa.Ctx)
+	sa.stmtSize = sa.prepareSize(sa.Ctx)
+	sa.stmtScan = sa.prepareScan(sa.Ctx)
+	sa.stmtClear = sa.prepareClear(sa.Ctx, sa.TableName)
+	sa.stmtClearTag = sa.prepareClear(sa.Ctx, sa.tagTableName())
 }
 
 // The lock of clearning expired cache
@@ -144,7 +319,11 @@ func (sa *SQLStore) cleanExpired() error {
 	sqlCleanMutex.Lock()
 	defer sqlCleanMutex.Unlock()
 
-	_, err := sa.stmtCleanExpired.Exec(time.Now().UnixMilli())
+	now := time.Now().UnixMilli()
+	if _, err := sa.stmtCleanExpired.Exec(now); err != nil {
+		return err
+	}
+	_, err := sa.stmtCleanExpiredTag.Exec(now)
 	return err
 }
 
@@ -168,8 +347,12 @@ func (sa *SQLStore) startCleanExpired() {
 }
 
 func (sa *SQLStore) Get(key string) ([]byte, error) {
+	return sa.GetContext(sa.Ctx, key)
+}
+
+func (sa *SQLStore) GetContext(ctx context.Context, key string) ([]byte, error) {
 	b := []byte{}
-	err := sa.stmtGet.QueryRowContext(sa.Ctx, key, time.Now().UnixMilli()).Scan(&b)
+	err := sa.stmtGet.QueryRowContext(ctx, key, time.Now().UnixMilli()).Scan(&b)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -180,6 +363,218 @@ func (sa *SQLStore) Get(key string) ([]byte, error) {
 }
 
 func (sa *SQLStore) Set(key string, val []byte, ttl time.Duration) error {
-	_, err := sa.stmtSet.ExecContext(sa.Ctx, key, val, time.Now().Add(ttl).UnixMilli())
+	return sa.SetContext(sa.Ctx, key, val, ttl)
+}
+
+func (sa *SQLStore) SetContext(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	_, err := sa.stmtSet.ExecContext(ctx, key, val, time.Now().Add(ttl).UnixMilli())
 	return err
 }
+
+func (sa *SQLStore) Delete(key string) error {
+	return sa.DeleteContext(sa.Ctx, key)
+}
+
+func (sa *SQLStore) DeleteContext(ctx context.Context, key string) error {
+	_, err := sa.stmtDelete.ExecContext(ctx, key)
+	return err
+}
+
+func (sa *SQLStore) Tag(key string, tags []string, ttl time.Duration) error {
+	return sa.TagContext(sa.Ctx, key, tags, ttl)
+}
+
+func (sa *SQLStore) TagContext(ctx context.Context, key string, tags []string, ttl time.Duration) error {
+	expiredAt := time.Now().Add(ttl).UnixMilli()
+	for _, tag := range tags {
+		if _, err := sa.stmtTag.ExecContext(ctx, tag, key, expiredAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sa *SQLStore) PurgeTag(tag string) error {
+	tx, err := sa.DB.BeginTx(sa.Ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.StmtContext(sa.Ctx, sa.stmtPurgeTagKeys).Exec(tag); err != nil {
+		return err
+	}
+	if _, err := tx.StmtContext(sa.Ctx, sa.stmtPurgeTag).Exec(tag); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (sa *SQLStore) Len() (int64, error) {
+	var n int64
+	err := sa.stmtLen.QueryRowContext(sa.Ctx, time.Now().UnixMilli()).Scan(&n)
+	return n, err
+}
+
+// SizeBytes returns the size of the keys and values, without the overhead
+// of the database
+func (sa *SQLStore) SizeBytes() (int64, error) {
+	var size int64
+	err := sa.stmtSize.QueryRowContext(sa.Ctx, time.Now().UnixMilli()).Scan(&size)
+	return size, err
+}
+
+// Scan pages through the keys in the order of the database collation
+func (sa *SQLStore) Scan(prefix string, cursor string, count int) ([]string, string, error) {
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+	pattern := likeEscaper.Replace(prefix) + "%"
+	rows, err := sa.stmtScan.QueryContext(sa.Ctx, pattern, cursor, time.Now().UnixMilli(), count)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var keys []string
+	last := ""
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(&last); err != nil {
+			return nil, "", err
+		}
+		n++
+		// LIKE ignores the case in SQLite and in some MySQL collations
+		if strings.HasPrefix(last, prefix) {
+			keys = append(keys, last)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	if n < count {
+		return keys, "", nil
+	}
+	return keys, last, nil
+}
+
+// Clear deletes the keys starting with prefix and their tags
+func (sa *SQLStore) Clear(prefix string) error {
+	args := []any{likeEscaper.Replace(prefix) + "%"}
+	if sa.dialect.PrefixCheck != "" {
+		args = append(args, prefix, prefix)
+	}
+
+	tx, err := sa.DB.BeginTx(sa.Ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.StmtContext(sa.Ctx, sa.stmtClear).Exec(args...); err != nil {
+		return err
+	}
+	if _, err := tx.StmtContext(sa.Ctx, sa.stmtClearTag).Exec(args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetMulti reads keys with `IN (...)` queries of at most batchSize keys
+func (sa *SQLStore) GetMulti(keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	now := time.Now().UnixMilli()
+	for chunk := range slices.Chunk(keys, batchSize) {
+		query := fmt.Sprintf(
+			"SELECT cache_key, value FROM %s WHERE cache_key IN (%s) AND expired_at > %s",
+			sa.TableName, sa.placeholders(len(chunk), 1), sa.placeholders(1, len(chunk)+1),
+		)
+		args := make([]any, 0, len(chunk)+1)
+		for _, key := range chunk {
+			args = append(args, key)
+		}
+		args = append(args, now)
+
+		if err := sa.getMulti(values, query, args); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func (sa *SQLStore) getMulti(values map[string][]byte, query string, args []any) error {
+	rows, err := sa.DB.QueryContext(sa.Ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		b := []byte{}
+		if err := rows.Scan(&key, &b); err != nil {
+			return err
+		}
+		values[key] = b
+	}
+	return rows.Err()
+}
+
+// SetMulti upserts items with multi-row inserts in one transaction
+func (sa *SQLStore) SetMulti(items map[string][]byte, ttl time.Duration) error {
+	onConflict := `ON CONFLICT (cache_key) DO UPDATE
+SET value = EXCLUDED.value, expired_at = EXCLUDED.expired_at`
+	if sa.DBName == MySQL {
+		onConflict = `ON DUPLICATE KEY
+UPDATE value = VALUES(value), expired_at = VALUES(expired_at)`
+	}
+	expiredAt := time.Now().Add(ttl).UnixMilli()
+
+	tx, err := sa.DB.BeginTx(sa.Ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	keys := slices.Sorted(maps.Keys(items))
+	for chunk := range slices.Chunk(keys, batchSize) {
+		rows := make([]string, len(chunk))
+		args := make([]any, 0, 3*len(chunk))
+		for i, key := range chunk {
+			rows[i] = "(" + sa.placeholders(3, 3*i+1) + ")"
+			args = append(args, key, items[key], expiredAt)
+		}
+		query := fmt.Sprintf(
+			"INSERT INTO %s (cache_key, value, expired_at) VALUES %s %s",
+			sa.TableName, strings.Join(rows, ", "), onConflict,
+		)
+		if _, err := tx.ExecContext(sa.Ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteMulti deletes keys with `IN (...)` statements in one transaction
+func (sa *SQLStore) DeleteMulti(keys []string) error {
+	tx, err := sa.DB.BeginTx(sa.Ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for chunk := range slices.Chunk(keys, batchSize) {
+		query := fmt.Sprintf(
+			"DELETE FROM %s WHERE cache_key IN (%s)",
+			sa.TableName, sa.placeholders(len(chunk), 1),
+		)
+		args := make([]any, len(chunk))
+		for i, key := range chunk {
+			args[i] = key
+		}
+		if _, err := tx.ExecContext(sa.Ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
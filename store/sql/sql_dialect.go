@@ -20,6 +20,11 @@ type sqlDialect struct {
 	TypeBytes   string
 	TypeBigInt  string
 	TypeBindVar string
+	// Format of the expression of the size in bytes of a column
+	OctetLength string
+	// Case sensitive comparison of the start of cache_key with the prefix
+	// bound twice, for databases where LIKE ignores the case
+	PrefixCheck string
 }
 
 var (
@@ -27,16 +32,22 @@ var (
 		TypeText:   "TEXT",
 		TypeBytes:  "BLOB",
 		TypeBigInt: "INTEGER",
+		// LENGTH counts the characters of TEXT
+		OctetLength: "LENGTH(CAST(%s AS BLOB))",
+		PrefixCheck: "SUBSTR(cache_key, 1, LENGTH(?)) = ?",
 	}
 	postgresqlDialect = &sqlDialect{
-		TypeText:   "TEXT",
-		TypeBytes:  "BYTEA",
-		TypeBigInt: "BIGINT",
+		TypeText:    "TEXT",
+		TypeBytes:   "BYTEA",
+		TypeBigInt:  "BIGINT",
+		OctetLength: "OCTET_LENGTH(%s)",
 	}
 	mysqlDialect = &sqlDialect{
-		TypeText:   "VARCHAR(255)",
-		TypeBytes:  "BLOB",
-		TypeBigInt: "BIGINT",
+		TypeText:    "VARCHAR(255)",
+		TypeBytes:   "BLOB",
+		TypeBigInt:  "BIGINT",
+		OctetLength: "LENGTH(%s)",
+		PrefixCheck: "CAST(LEFT(cache_key, CHAR_LENGTH(?)) AS BINARY) = CAST(? AS BINARY)",
 	}
 )
 
@@ -11,6 +11,7 @@ toolchain go1.24.0
 require (
 	github.com/go-sql-driver/mysql v1.9.0
 	github.com/lib/pq v1.10.9
+	github.com/sdvcrx/echo-cache/store v0.3.0
 	github.com/sdvcrx/echo-cache/store/sql v0.3.0
 	github.com/stretchr/testify v1.10.0
 	modernc.org/sqlite v1.35.0
@@ -25,7 +26,6 @@ require (
 	github.com/ncruces/go-strftime v0.1.9 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
-	github.com/sdvcrx/echo-cache/store v0.3.0 // indirect
 	golang.org/x/exp v0.0.0-20250218142911-aa4b98e5adaa // indirect
 	golang.org/x/sys v0.30.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -3,11 +3,13 @@ package sqlstore_test
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"testing"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
+	"github.com/sdvcrx/echo-cache/store"
 	sqlStore "github.com/sdvcrx/echo-cache/store/sql"
 	"github.com/stretchr/testify/assert"
 	_ "modernc.org/sqlite"
@@ -78,6 +80,136 @@ func TestCacheSQLStore(t *testing.T) {
 				}
 			})
 
+			t.Run("Delete", func(t *testing.T) {
+				key := "deleted"
+				assert.NoError(t, sa.Set(key, body, time.Minute))
+				assert.NoError(t, sa.Delete(key))
+
+				res, err := sa.Get(key)
+				if assert.NoError(t, err) {
+					assert.Nil(t, res)
+				}
+			})
+
+			t.Run("PurgeTag", func(t *testing.T) {
+				ts := sa.(store.TagStore)
+				for _, key := range []string{"a", "b", "c"} {
+					assert.NoError(t, sa.Set(key, body, time.Minute))
+				}
+				assert.NoError(t, ts.Tag("a", []string{"product:1", "product:2"}, time.Minute))
+				assert.NoError(t, ts.Tag("b", []string{"product:1"}, time.Minute))
+				// tag again
+				assert.NoError(t, ts.Tag("b", []string{"product:1"}, time.Minute))
+
+				assert.NoError(t, ts.PurgeTag("product:1"))
+				for key, expected := range map[string][]byte{"a": nil, "b": nil, "c": body} {
+					res, err := sa.Get(key)
+					if assert.NoError(t, err) {
+						assert.Equal(t, expected, res)
+					}
+				}
+
+				// purge a missing tag
+				assert.NoError(t, ts.PurgeTag("product:3"))
+			})
+
+			t.Run("Context done", func(t *testing.T) {
+				cs := sa.(store.ContextStore)
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				_, err := cs.GetContext(ctx, key)
+				assert.ErrorIs(t, err, context.Canceled)
+				assert.ErrorIs(t, cs.SetContext(ctx, key, body, time.Minute), context.Canceled)
+				assert.ErrorIs(t, cs.DeleteContext(ctx, key), context.Canceled)
+				assert.ErrorIs(t, sa.(store.ContextTagStore).TagContext(ctx, key, []string{"tag"}, time.Minute), context.Canceled)
+			})
+
+			t.Run("Inspector", func(t *testing.T) {
+				is := sa.(store.Inspector)
+				n0, err := is.Len()
+				assert.NoError(t, err)
+				size0, err := is.SizeBytes()
+				assert.NoError(t, err)
+
+				for _, key := range []string{"scan-1", "scan-2", "scan-3", "scan_4"} {
+					assert.NoError(t, sa.Set(key, body, time.Minute))
+				}
+				assert.NoError(t, sa.Set("scan-0", body, -time.Minute))
+
+				n, err := is.Len()
+				assert.NoError(t, err)
+				assert.Equal(t, n0+4, n)
+				size, err := is.SizeBytes()
+				assert.NoError(t, err)
+				assert.Equal(t, size0+4*8, size)
+
+				keys, cursor, err := is.Scan("scan-", "", 2)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"scan-1", "scan-2"}, keys)
+				keys, cursor, err = is.Scan("scan-", cursor, 2)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"scan-3"}, keys)
+				assert.Equal(t, "", cursor)
+
+				for _, key := range []string{"scan-0", "scan-1", "scan-2", "scan-3", "scan_4"} {
+					assert.NoError(t, sa.Delete(key))
+				}
+			})
+
+			t.Run("Clear", func(t *testing.T) {
+				cl := sa.(store.Clearer)
+				keys := []string{"app1-GET-/", "app1-GET-/posts", "APP1-GET-/", "app1_GET-/", "app2-GET-/"}
+				for _, key := range keys {
+					assert.NoError(t, sa.Set(key, body, time.Minute))
+				}
+				assert.NoError(t, sa.(store.TagStore).Tag("app1-GET-/", []string{"post"}, time.Minute))
+
+				assert.NoError(t, cl.Clear("app1-"))
+				for i, key := range keys {
+					res, err := sa.Get(key)
+					if assert.NoError(t, err) {
+						if i < 2 {
+							assert.Nil(t, res, key)
+						} else {
+							assert.Equal(t, body, res, key)
+						}
+					}
+				}
+
+				assert.NoError(t, cl.Clear(""))
+				n, err := sa.(store.Inspector).Len()
+				assert.NoError(t, err)
+				assert.Equal(t, int64(0), n)
+			})
+
+			t.Run("Multi", func(t *testing.T) {
+				bs := sa.(store.BatchStore)
+				items := map[string][]byte{}
+				keys := []string{"missing"}
+				for i := range 150 {
+					k := fmt.Sprintf("multi-%d", i)
+					items[k] = []byte(k)
+					keys = append(keys, k)
+				}
+
+				assert.NoError(t, bs.SetMulti(items, time.Minute))
+				values, err := bs.GetMulti(keys)
+				assert.NoError(t, err)
+				assert.Equal(t, items, values)
+
+				// override
+				assert.NoError(t, bs.SetMulti(map[string][]byte{"multi-0": body}, time.Minute))
+				res, err := sa.Get("multi-0")
+				assert.NoError(t, err)
+				assert.Equal(t, body, res)
+
+				assert.NoError(t, bs.DeleteMulti(keys))
+				values, err = bs.GetMulti(keys)
+				assert.NoError(t, err)
+				assert.Empty(t, values)
+			})
+
 			t.Run("Set with TTL", func(t *testing.T) {
 				ttl := time.Second
 				// resp := NewResponse(201, nil, []byte("NOT OK"))
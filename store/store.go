@@ -1,8 +1,261 @@
 package store
 
-import "time"
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrNotSupported is returned by store wrappers when the wrapped store
+// doesn't support the operation
+var ErrNotSupported = errors.New("store: operation not supported")
 
 type Store interface {
 	Get(key string) ([]byte, error)
 	Set(key string, val []byte, ttl time.Duration) error
+	Delete(key string) error
+}
+
+// TagStore is a Store which groups keys by tags to delete them together
+type TagStore interface {
+	Store
+	// Tag associates key, which is saved with ttl, with tags
+	Tag(key string, tags []string, ttl time.Duration) error
+	// PurgeTag deletes all the keys associated with tag
+	PurgeTag(tag string) error
+}
+
+// StreamStore is a Store which writes and reads values in chunks,
+// so large values don't need to be held in memory
+type StreamStore interface {
+	Store
+	// SetStream saves everything read from r under key. The value becomes
+	// visible once r returns io.EOF, it is discarded when r fails.
+	SetStream(key string, r io.Reader, ttl time.Duration) error
+	// GetStream returns a reader of the value saved under key,
+	// or nil when the key is missing. Readers implementing io.Seeker
+	// let the middleware serve Range requests from the value.
+	GetStream(key string) (io.ReadCloser, error)
+}
+
+// Invalidator broadcasts the keys changed by an instance
+// to the other instances sharing a store
+type Invalidator interface {
+	// Publish notifies the other instances that key changed
+	Publish(key string) error
+	// Subscribe calls fn with the keys published by the other instances
+	Subscribe(fn func(key string)) error
+}
+
+// ContextStore is a Store whose operations are bounded by a context
+type ContextStore interface {
+	Store
+	GetContext(ctx context.Context, key string) ([]byte, error)
+	SetContext(ctx context.Context, key string, val []byte, ttl time.Duration) error
+	DeleteContext(ctx context.Context, key string) error
+}
+
+// ContextTagStore is a TagStore whose Tag is bounded by a context
+type ContextTagStore interface {
+	TagStore
+	TagContext(ctx context.Context, key string, tags []string, ttl time.Duration) error
+}
+
+// ContextStreamStore is a StreamStore whose operations are bounded by a context
+type ContextStreamStore interface {
+	StreamStore
+	// SetStreamContext saves r like SetStream, the value is discarded
+	// when ctx is done before r returns io.EOF
+	SetStreamContext(ctx context.Context, key string, r io.Reader, ttl time.Duration) error
+	// GetStreamContext opens the value like GetStream,
+	// the reads of the returned reader are bounded by ctx too
+	GetStreamContext(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// Inspector is a Store which reports what it holds
+type Inspector interface {
+	Store
+	// Len returns the number of keys saved, expired keys may be counted
+	// until the store removes them
+	Len() (int64, error)
+	// SizeBytes returns the space used by the saved keys and values
+	SizeBytes() (int64, error)
+	// Scan returns a page of the keys starting with prefix and the cursor of
+	// the next page, which is empty after the last one. The first page is
+	// read with an empty cursor, count is a hint of the page size.
+	Scan(prefix string, cursor string, count int) (keys []string, next string, err error)
+}
+
+// PrefixSizer is a Store which reports the space used by the keys of a
+// prefix, so a store shared with other applications reports its own keys
+type PrefixSizer interface {
+	Store
+	// SizeBytesPrefix returns the space used by the keys starting
+	// with prefix and their values
+	SizeBytesPrefix(prefix string) (int64, error)
+}
+
+// Clearer is a Store which deletes keys by prefix
+type Clearer interface {
+	Store
+	// Clear deletes the keys starting with prefix, every key when prefix
+	// is empty. Keys saved while clearing may be kept.
+	Clear(prefix string) error
+}
+
+// BatchStore is a Store which reads and writes several keys at once
+type BatchStore interface {
+	Store
+	// GetMulti returns the values of keys, missing keys are left out
+	GetMulti(keys []string) (map[string][]byte, error)
+	// SetMulti saves the values of items with ttl
+	SetMulti(items map[string][]byte, ttl time.Duration) error
+	DeleteMulti(keys []string) error
+}
+
+// GetMulti returns the values of keys with one call to stores implementing
+// BatchStore and one Get per key otherwise, missing keys are left out
+func GetMulti(s Store, keys []string) (map[string][]byte, error) {
+	if bs, ok := s.(BatchStore); ok {
+		return bs.GetMulti(keys)
+	}
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		val, err := s.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if val != nil {
+			values[key] = val
+		}
+	}
+	return values, nil
+}
+
+// SetMulti saves the values of items with one call to stores implementing
+// BatchStore and one Set per key otherwise
+func SetMulti(s Store, items map[string][]byte, ttl time.Duration) error {
+	if bs, ok := s.(BatchStore); ok {
+		return bs.SetMulti(items, ttl)
+	}
+	for key, val := range items {
+		if err := s.Set(key, val, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteMulti deletes keys with one call to stores implementing
+// BatchStore and one Delete per key otherwise
+func DeleteMulti(s Store, keys []string) error {
+	if bs, ok := s.(BatchStore); ok {
+		return bs.DeleteMulti(keys)
+	}
+	for _, key := range keys {
+		if err := s.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TagContext calls Tag with ctx on stores implementing ContextTagStore,
+// other stores are called once ctx is checked like WithContext
+func TagContext(ctx context.Context, s TagStore, key string, tags []string, ttl time.Duration) error {
+	if cs, ok := s.(ContextTagStore); ok {
+		return cs.TagContext(ctx, key, tags, ttl)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Tag(key, tags, ttl)
+}
+
+// SetStreamContext calls SetStream with ctx on stores implementing
+// ContextStreamStore, other stores read r until ctx is done
+func SetStreamContext(ctx context.Context, s StreamStore, key string, r io.Reader, ttl time.Duration) error {
+	if cs, ok := s.(ContextStreamStore); ok {
+		return cs.SetStreamContext(ctx, key, r, ttl)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.SetStream(key, contextReader{ctx, r}, ttl)
+}
+
+// GetStreamContext calls GetStream with ctx on stores implementing
+// ContextStreamStore, other stores are called once ctx is checked
+func GetStreamContext(ctx context.Context, s StreamStore, key string) (io.ReadCloser, error) {
+	if cs, ok := s.(ContextStreamStore); ok {
+		return cs.GetStreamContext(ctx, key)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.GetStream(key)
+}
+
+// contextReader fails once ctx is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// ScanKeys pages through keys for stores listing all their keys at once,
+// the cursor is the last key of the previous page
+func ScanKeys(keys []string, prefix string, cursor string, count int) ([]string, string) {
+	keys = slices.DeleteFunc(keys, func(key string) bool {
+		return !strings.HasPrefix(key, prefix) || (cursor != "" && key <= cursor)
+	})
+	slices.Sort(keys)
+	if count <= 0 || len(keys) <= count {
+		return keys, ""
+	}
+	return keys[:count], keys[count-1]
+}
+
+// WithContext returns s as a ContextStore. Stores without native context
+// support are adapted to check the context before each operation,
+// a running operation can't be interrupted.
+func WithContext(s Store) ContextStore {
+	if cs, ok := s.(ContextStore); ok {
+		return cs
+	}
+	return contextAdapter{s}
+}
+
+type contextAdapter struct {
+	Store
+}
+
+func (a contextAdapter) GetContext(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.Get(key)
+}
+
+func (a contextAdapter) SetContext(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.Set(key, val, ttl)
+}
+
+func (a contextAdapter) DeleteContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.Delete(key)
 }
@@ -0,0 +1,203 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mapStore struct {
+	data sync.Map
+}
+
+func (m *mapStore) Get(key string) ([]byte, error) {
+	v, _ := m.data.Load(key)
+	if v == nil {
+		return nil, nil
+	}
+	return v.([]byte), nil
+}
+
+func (m *mapStore) Set(key string, val []byte, ttl time.Duration) error {
+	m.data.Store(key, val)
+	return nil
+}
+
+func (m *mapStore) Delete(key string) error {
+	m.data.Delete(key)
+	return nil
+}
+
+func TestWithContext(t *testing.T) {
+	cs := WithContext(&mapStore{})
+	key := "cacheKey"
+	body := []byte("OK")
+
+	t.Run("Keep ContextStore", func(t *testing.T) {
+		assert.Equal(t, cs, WithContext(cs))
+	})
+
+	t.Run("Get/Set/Delete", func(t *testing.T) {
+		ctx := context.Background()
+		assert.NoError(t, cs.SetContext(ctx, key, body, 0))
+
+		r, err := cs.GetContext(ctx, key)
+		assert.NoError(t, err)
+		assert.Equal(t, body, r)
+
+		assert.NoError(t, cs.DeleteContext(ctx, key))
+		r, err = cs.GetContext(ctx, key)
+		assert.NoError(t, err)
+		assert.Nil(t, r)
+	})
+
+	t.Run("Context done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := cs.GetContext(ctx, key)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorIs(t, cs.SetContext(ctx, key, body, 0), context.Canceled)
+		assert.ErrorIs(t, cs.DeleteContext(ctx, key), context.Canceled)
+	})
+}
+
+// streamMapStore is a StreamStore without context support
+type streamMapStore struct {
+	mapStore
+}
+
+func (m *streamMapStore) SetStream(key string, r io.Reader, ttl time.Duration) error {
+	val, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return m.Set(key, val, ttl)
+}
+
+func (m *streamMapStore) GetStream(key string) (io.ReadCloser, error) {
+	val, err := m.Get(key)
+	if val == nil || err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(val)), nil
+}
+
+// cancelReader cancels its context once read
+type cancelReader struct {
+	r      io.Reader
+	cancel context.CancelFunc
+}
+
+func (cr cancelReader) Read(p []byte) (int, error) {
+	cr.cancel()
+	return cr.r.Read(p)
+}
+
+func TestStreamContext(t *testing.T) {
+	s := &streamMapStore{}
+	key := "cacheKey"
+	body := []byte("OK")
+
+	t.Run("Set/Get", func(t *testing.T) {
+		ctx := context.Background()
+		assert.NoError(t, SetStreamContext(ctx, s, key, bytes.NewReader(body), 0))
+
+		r, err := GetStreamContext(ctx, s, key)
+		assert.NoError(t, err)
+		val, _ := io.ReadAll(r)
+		assert.Equal(t, body, val)
+	})
+
+	t.Run("Context done while reading", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		r := io.MultiReader(cancelReader{bytes.NewReader(body), cancel}, bytes.NewReader(body))
+		assert.ErrorIs(t, SetStreamContext(ctx, s, "other", r, 0), context.Canceled)
+
+		val, _ := s.Get("other")
+		assert.Nil(t, val)
+	})
+
+	t.Run("Context done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := GetStreamContext(ctx, s, key)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestScanKeys(t *testing.T) {
+	keys := []string{"b-2", "a-1", "b-1", "b-3", "c-1"}
+
+	page, next := ScanKeys(slices.Clone(keys), "b-", "", 2)
+	assert.Equal(t, []string{"b-1", "b-2"}, page)
+	assert.Equal(t, "b-2", next)
+
+	page, next = ScanKeys(slices.Clone(keys), "b-", next, 2)
+	assert.Equal(t, []string{"b-3"}, page)
+	assert.Equal(t, "", next)
+
+	page, next = ScanKeys(slices.Clone(keys), "", "", 0)
+	assert.Equal(t, []string{"a-1", "b-1", "b-2", "b-3", "c-1"}, page)
+	assert.Equal(t, "", next)
+}
+
+// batchMapStore counts the batch calls
+type batchMapStore struct {
+	mapStore
+	calls int
+}
+
+func (m *batchMapStore) GetMulti(keys []string) (map[string][]byte, error) {
+	m.calls++
+	values := make(map[string][]byte)
+	for _, key := range keys {
+		if v, _ := m.Get(key); v != nil {
+			values[key] = v
+		}
+	}
+	return values, nil
+}
+
+func (m *batchMapStore) SetMulti(items map[string][]byte, ttl time.Duration) error {
+	m.calls++
+	for key, val := range items {
+		m.Set(key, val, ttl)
+	}
+	return nil
+}
+
+func (m *batchMapStore) DeleteMulti(keys []string) error {
+	m.calls++
+	for _, key := range keys {
+		m.Delete(key)
+	}
+	return nil
+}
+
+func TestMulti(t *testing.T) {
+	batch := &batchMapStore{}
+	for name, s := range map[string]Store{"Store": &mapStore{}, "BatchStore": batch} {
+		t.Run(name, func(t *testing.T) {
+			items := map[string][]byte{"a": []byte("1"), "b": []byte("2")}
+			assert.NoError(t, SetMulti(s, items, time.Minute))
+
+			values, err := GetMulti(s, []string{"a", "b", "missing"})
+			assert.NoError(t, err)
+			assert.Equal(t, items, values)
+
+			assert.NoError(t, DeleteMulti(s, []string{"a", "missing"}))
+			values, err = GetMulti(s, []string{"a", "b"})
+			assert.NoError(t, err)
+			assert.Equal(t, map[string][]byte{"b": []byte("2")}, values)
+		})
+	}
+	assert.Equal(t, 4, batch.calls)
+}
@@ -0,0 +1,287 @@
+package tieredstore
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/sdvcrx/echo-cache/store"
+	memorystore "github.com/sdvcrx/echo-cache/store/memory"
+)
+
+const (
+	DefaultL1Size = 1024
+	DefaultL1TTL  = 10 * time.Second
+)
+
+// Published instead of a key when a tag is purged or a prefix cleared
+const (
+	tagMessagePrefix   = "\x00tag:"
+	clearMessagePrefix = "\x00clear:"
+)
+
+type TieredStoreOption struct {
+	// Local store, defaults to a memory store of DefaultL1Size entries
+	L1 store.Store
+	// Shared store
+	L2 store.Store
+	// TTL of the values kept in L1, capped by their TTL. Defaults to DefaultL1TTL.
+	L1TTL time.Duration
+	// Broadcasts the keys set or deleted so the other instances drop them
+	// from their L1, without it they are dropped after L1TTL
+	Invalidator store.Invalidator
+}
+
+// TieredStore keeps the values of a shared store in a local store for a short time
+type TieredStore struct {
+	TieredStoreOption
+	l2 store.ContextStore
+}
+
+func New(option TieredStoreOption) store.Store {
+	if option.L1 == nil {
+		option.L1 = memorystore.New(DefaultL1Size)
+	}
+	if option.L1TTL == 0 {
+		option.L1TTL = DefaultL1TTL
+	}
+
+	ts := &TieredStore{
+		TieredStoreOption: option,
+		l2:                store.WithContext(option.L2),
+	}
+	if option.Invalidator != nil {
+		if err := option.Invalidator.Subscribe(ts.invalidate); err != nil {
+			log.Println("Failed to subscribe to tiered store invalidations", err)
+		}
+	}
+	return ts
+}
+
+var _ store.Store = (*TieredStore)(nil)
+var _ store.ContextStore = (*TieredStore)(nil)
+var _ store.TagStore = (*TieredStore)(nil)
+var _ store.ContextTagStore = (*TieredStore)(nil)
+var _ store.Inspector = (*TieredStore)(nil)
+var _ store.PrefixSizer = (*TieredStore)(nil)
+var _ store.Clearer = (*TieredStore)(nil)
+var _ store.BatchStore = (*TieredStore)(nil)
+
+// invalidate drops a key, a tag or a prefix published by another instance from L1
+func (ts *TieredStore) invalidate(key string) {
+	if tag, ok := strings.CutPrefix(key, tagMessagePrefix); ok {
+		// values copied to L1 by a Get are not tagged there,
+		// the whole L1 is cleared when possible
+		if l1, ok := ts.L1.(store.Clearer); ok {
+			l1.Clear("")
+		} else if l1, ok := ts.L1.(store.TagStore); ok {
+			l1.PurgeTag(tag)
+		}
+		return
+	}
+	if prefix, ok := strings.CutPrefix(key, clearMessagePrefix); ok {
+		if l1, ok := ts.L1.(store.Clearer); ok {
+			l1.Clear(prefix)
+		}
+		return
+	}
+	ts.L1.Delete(key)
+}
+
+func (ts *TieredStore) publish(key string) error {
+	if ts.Invalidator == nil {
+		return nil
+	}
+	return ts.Invalidator.Publish(key)
+}
+
+func (ts *TieredStore) l1TTL(ttl time.Duration) time.Duration {
+	if ttl > 0 {
+		return min(ttl, ts.L1TTL)
+	}
+	return ts.L1TTL
+}
+
+func (ts *TieredStore) Get(key string) ([]byte, error) {
+	return ts.GetContext(context.Background(), key)
+}
+
+func (ts *TieredStore) GetContext(ctx context.Context, key string) ([]byte, error) {
+	if val, err := ts.L1.Get(key); err == nil && val != nil {
+		return val, nil
+	}
+
+	val, err := ts.l2.GetContext(ctx, key)
+	if err != nil || val == nil {
+		return nil, err
+	}
+	// the remaining TTL in L2 is unknown
+	ts.L1.Set(key, val, ts.L1TTL)
+	return val, nil
+}
+
+func (ts *TieredStore) Set(key string, val []byte, ttl time.Duration) error {
+	return ts.SetContext(context.Background(), key, val, ttl)
+}
+
+func (ts *TieredStore) SetContext(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	if err := ts.l2.SetContext(ctx, key, val, ttl); err != nil {
+		return err
+	}
+	if err := ts.L1.Set(key, val, ts.l1TTL(ttl)); err != nil {
+		return err
+	}
+	return ts.publish(key)
+}
+
+func (ts *TieredStore) Delete(key string) error {
+	return ts.DeleteContext(context.Background(), key)
+}
+
+func (ts *TieredStore) DeleteContext(ctx context.Context, key string) error {
+	if err := ts.l2.DeleteContext(ctx, key); err != nil {
+		return err
+	}
+	if err := ts.L1.Delete(key); err != nil {
+		return err
+	}
+	return ts.publish(key)
+}
+
+// GetMulti reads the keys missing from L1 from L2 at once
+func (ts *TieredStore) GetMulti(keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	var missing []string
+	for _, key := range keys {
+		if val, err := ts.L1.Get(key); err == nil && val != nil {
+			values[key] = val
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	found, err := store.GetMulti(ts.L2, missing)
+	if err != nil {
+		return nil, err
+	}
+	for key, val := range found {
+		values[key] = val
+	}
+	// the remaining TTL in L2 is unknown
+	store.SetMulti(ts.L1, found, ts.L1TTL)
+	return values, nil
+}
+
+func (ts *TieredStore) SetMulti(items map[string][]byte, ttl time.Duration) error {
+	if err := store.SetMulti(ts.L2, items, ttl); err != nil {
+		return err
+	}
+	if err := store.SetMulti(ts.L1, items, ts.l1TTL(ttl)); err != nil {
+		return err
+	}
+	for key := range items {
+		if err := ts.publish(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ts *TieredStore) DeleteMulti(keys []string) error {
+	if err := store.DeleteMulti(ts.L2, keys); err != nil {
+		return err
+	}
+	if err := store.DeleteMulti(ts.L1, keys); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := ts.publish(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ts *TieredStore) Tag(key string, tags []string, ttl time.Duration) error {
+	return ts.TagContext(context.Background(), key, tags, ttl)
+}
+
+func (ts *TieredStore) TagContext(ctx context.Context, key string, tags []string, ttl time.Duration) error {
+	l2, ok := ts.L2.(store.TagStore)
+	if !ok {
+		return store.ErrNotSupported
+	}
+	if err := store.TagContext(ctx, l2, key, tags, ttl); err != nil {
+		return err
+	}
+	if l1, ok := ts.L1.(store.TagStore); ok {
+		return store.TagContext(ctx, l1, key, tags, ts.l1TTL(ttl))
+	}
+	return nil
+}
+
+// PurgeTag purges tag from L2 and clears the L1 of every instance, since
+// values copied to L1 by a Get are not tagged there. An L1 which doesn't
+// implement store.Clearer keeps these values up to L1TTL.
+func (ts *TieredStore) PurgeTag(tag string) error {
+	l2, ok := ts.L2.(store.TagStore)
+	if !ok {
+		return store.ErrNotSupported
+	}
+	if err := l2.PurgeTag(tag); err != nil {
+		return err
+	}
+	ts.invalidate(tagMessagePrefix + tag)
+	return ts.publish(tagMessagePrefix + tag)
+}
+
+// Len, SizeBytes, SizeBytesPrefix and Scan inspect L2, which holds every value
+func (ts *TieredStore) Len() (int64, error) {
+	is, ok := ts.L2.(store.Inspector)
+	if !ok {
+		return 0, store.ErrNotSupported
+	}
+	return is.Len()
+}
+
+func (ts *TieredStore) SizeBytes() (int64, error) {
+	is, ok := ts.L2.(store.Inspector)
+	if !ok {
+		return 0, store.ErrNotSupported
+	}
+	return is.SizeBytes()
+}
+
+func (ts *TieredStore) SizeBytesPrefix(prefix string) (int64, error) {
+	ps, ok := ts.L2.(store.PrefixSizer)
+	if !ok {
+		return 0, store.ErrNotSupported
+	}
+	return ps.SizeBytesPrefix(prefix)
+}
+
+func (ts *TieredStore) Scan(prefix string, cursor string, count int) ([]string, string, error) {
+	is, ok := ts.L2.(store.Inspector)
+	if !ok {
+		return nil, "", store.ErrNotSupported
+	}
+	return is.Scan(prefix, cursor, count)
+}
+
+// Clear clears L2 and the L1 of every instance, an L1 which doesn't
+// implement store.Clearer keeps the values up to L1TTL
+func (ts *TieredStore) Clear(prefix string) error {
+	l2, ok := ts.L2.(store.Clearer)
+	if !ok {
+		return store.ErrNotSupported
+	}
+	if err := l2.Clear(prefix); err != nil {
+		return err
+	}
+	ts.invalidate(clearMessagePrefix + prefix)
+	return ts.publish(clearMessagePrefix + prefix)
+}
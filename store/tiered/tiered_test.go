@@ -0,0 +1,199 @@
+package tieredstore
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sdvcrx/echo-cache/store"
+	memorystore "github.com/sdvcrx/echo-cache/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+// bus delivers the published keys to the other subscribers
+type bus struct {
+	mu   sync.Mutex
+	subs []func(key string)
+}
+
+type busInvalidator struct {
+	bus *bus
+	id  int
+}
+
+func (b *bus) join() *busInvalidator {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, nil)
+	return &busInvalidator{bus: b, id: len(b.subs) - 1}
+}
+
+func (i *busInvalidator) Publish(key string) error {
+	i.bus.mu.Lock()
+	defer i.bus.mu.Unlock()
+	for id, fn := range i.bus.subs {
+		if id != i.id && fn != nil {
+			fn(key)
+		}
+	}
+	return nil
+}
+
+func (i *busInvalidator) Subscribe(fn func(key string)) error {
+	i.bus.mu.Lock()
+	defer i.bus.mu.Unlock()
+	i.bus.subs[i.id] = fn
+	return nil
+}
+
+func TestTieredStore(t *testing.T) {
+	l1 := memorystore.New(1024)
+	l2 := memorystore.New(1024)
+	ts := New(TieredStoreOption{L1: l1, L2: l2, L1TTL: time.Minute})
+	key := "cacheKey"
+	body := []byte("OK")
+
+	t.Run("Write through", func(t *testing.T) {
+		assert.NoError(t, ts.Set(key, body, time.Hour))
+		v, _ := l1.Get(key)
+		assert.Equal(t, body, v)
+		v, _ = l2.Get(key)
+		assert.Equal(t, body, v)
+	})
+
+	t.Run("Populate L1 on L2 hit", func(t *testing.T) {
+		assert.NoError(t, l2.Set("shared", body, time.Hour))
+
+		v, err := ts.Get("shared")
+		assert.NoError(t, err)
+		assert.Equal(t, body, v)
+		v, _ = l1.Get("shared")
+		assert.Equal(t, body, v)
+	})
+
+	t.Run("Serve from L1", func(t *testing.T) {
+		assert.NoError(t, l2.Delete(key))
+
+		v, err := ts.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, body, v)
+	})
+
+	t.Run("L1 TTL", func(t *testing.T) {
+		ts := New(TieredStoreOption{L1: l1, L2: l2, L1TTL: 10 * time.Millisecond})
+		assert.NoError(t, ts.Set("short", body, time.Hour))
+		assert.NoError(t, l2.Delete("short"))
+		time.Sleep(20 * time.Millisecond)
+
+		v, err := ts.Get("short")
+		assert.NoError(t, err)
+		assert.Nil(t, v)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, ts.Set(key, body, time.Hour))
+		assert.NoError(t, ts.Delete(key))
+
+		v, err := ts.Get(key)
+		assert.NoError(t, err)
+		assert.Nil(t, v)
+	})
+
+	t.Run("Multi", func(t *testing.T) {
+		bs := ts.(store.BatchStore)
+		assert.NoError(t, bs.SetMulti(map[string][]byte{"a": body}, time.Hour))
+		v, _ := l1.Get("a")
+		assert.Equal(t, body, v)
+		assert.NoError(t, l2.Set("b", body, time.Hour))
+
+		values, err := bs.GetMulti([]string{"a", "b", "missing"})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string][]byte{"a": body, "b": body}, values)
+		v, _ = l1.Get("b")
+		assert.Equal(t, body, v)
+
+		assert.NoError(t, bs.DeleteMulti([]string{"a", "b"}))
+		values, err = bs.GetMulti([]string{"a", "b"})
+		assert.NoError(t, err)
+		assert.Empty(t, values)
+	})
+
+	t.Run("Context done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := ts.(store.ContextStore).GetContext(ctx, "missing")
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestTieredStoreInvalidation(t *testing.T) {
+	l2 := memorystore.New(1024)
+	b := &bus{}
+	first := New(TieredStoreOption{L2: l2, L1TTL: time.Minute, Invalidator: b.join()})
+	second := New(TieredStoreOption{L2: l2, L1TTL: time.Minute, Invalidator: b.join()})
+	key := "cacheKey"
+
+	assert.NoError(t, first.Set(key, []byte("v1"), time.Hour))
+	v, _ := second.Get(key)
+	assert.Equal(t, []byte("v1"), v)
+
+	t.Run("Set", func(t *testing.T) {
+		assert.NoError(t, first.Set(key, []byte("v2"), time.Hour))
+		v, _ := second.Get(key)
+		assert.Equal(t, []byte("v2"), v)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, first.Delete(key))
+		v, _ := second.Get(key)
+		assert.Nil(t, v)
+	})
+
+	t.Run("PurgeTag", func(t *testing.T) {
+		assert.NoError(t, second.Set(key, []byte("v3"), time.Hour))
+		assert.NoError(t, second.(store.TagStore).Tag(key, []string{"tag"}, time.Hour))
+		assert.NoError(t, first.(store.TagStore).PurgeTag("tag"))
+
+		v, _ := second.Get(key)
+		assert.Nil(t, v)
+	})
+
+	t.Run("PurgeTag of values read from L2", func(t *testing.T) {
+		assert.NoError(t, l2.Set(key, []byte("v3"), time.Hour))
+		assert.NoError(t, l2.(store.TagStore).Tag(key, []string{"tag"}, time.Hour))
+		for _, ts := range []store.Store{first, second} {
+			v, _ := ts.Get(key)
+			assert.Equal(t, []byte("v3"), v)
+		}
+		assert.NoError(t, first.(store.TagStore).PurgeTag("tag"))
+
+		for _, ts := range []store.Store{first, second} {
+			v, _ := ts.Get(key)
+			assert.Nil(t, v)
+		}
+	})
+
+	t.Run("SetMulti", func(t *testing.T) {
+		assert.NoError(t, second.Set(key, []byte("v5"), time.Hour))
+		assert.NoError(t, first.(store.BatchStore).SetMulti(map[string][]byte{key: []byte("v6")}, time.Hour))
+		v, _ := second.Get(key)
+		assert.Equal(t, []byte("v6"), v)
+
+		assert.NoError(t, first.(store.BatchStore).DeleteMulti([]string{key}))
+		v, _ = second.Get(key)
+		assert.Nil(t, v)
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		assert.NoError(t, second.Set(key, []byte("v4"), time.Hour))
+		assert.NoError(t, second.Set("other", []byte("v4"), time.Hour))
+		assert.NoError(t, first.(store.Clearer).Clear("cache"))
+
+		v, _ := second.Get(key)
+		assert.Nil(t, v)
+		v, _ = second.Get("other")
+		assert.Equal(t, []byte("v4"), v)
+	})
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sdvcrx/echo-cache/store"
+)
+
+// Response header listing the space separated tags of the response,
+// it is not saved with the cached response.
+const HeaderSurrogateKey = "Surrogate-Key"
+
+const tagsContextKey = "echo-cache-tags"
+
+var ErrTagsNotSupported = errors.New("echo-cache: store doesn't support tags")
+
+// AddTags attaches tags to the response of c, the cached response can
+// then be purged with `CacheConfig.PurgeTag`.
+func AddTags(c echo.Context, tags ...string) {
+	existing, _ := c.Get(tagsContextKey).([]string)
+	c.Set(tagsContextKey, append(slices.Clip(existing), tags...))
+}
+
+// responseTags returns the tags attached with `AddTags`
+// and listed in the `Surrogate-Key` header
+func responseTags(c echo.Context) []string {
+	tags, _ := c.Get(tagsContextKey).([]string)
+	tags = slices.Clone(tags)
+	for _, line := range c.Response().Header().Values(HeaderSurrogateKey) {
+		tags = append(tags, strings.Fields(line)...)
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// PurgeTag deletes all the cached responses tagged with tag,
+// config must use the same Store as the middleware.
+func (config CacheConfig) PurgeTag(tag string) error {
+	if config.Store == nil {
+		return ErrStoreRequired
+	}
+	ts, ok := config.Store.(store.TagStore)
+	if !ok {
+		return ErrTagsNotSupported
+	}
+	err := ts.PurgeTag(tag)
+	if errors.Is(err, store.ErrNotSupported) {
+		// a wrapper of a store without tags
+		return ErrTagsNotSupported
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// parseVary returns the sorted canonical header names listed in `Vary` headers
+func parseVary(header http.Header) []string {
+	var names []string
+	for _, line := range header.Values(echo.HeaderVary) {
+		for _, name := range strings.Split(line, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// varyKey derives the secondary key of a variant from
+// the values of the request headers named by `vary`
+func varyKey(key string, vary []string, header http.Header) string {
+	values := url.Values{}
+	for _, name := range vary {
+		values.Set(name, strings.Join(header.Values(name), ","))
+	}
+	return fmt.Sprintf("%s-vary-%s", key, values.Encode())
+}
+
+// newVariantIndex creates the index of the variants saved since createdAt
+func newVariantIndex(vary []string, createdAt int64) *Response {
+	return &Response{
+		Vary:      vary,
+		CreatedAt: createdAt,
+	}
+}
+
+func (r *Response) isVariantIndex() bool {
+	return r != nil && len(r.Vary) > 0
+}
+
+// varyKeyOf returns the key a request with `header` would read resp from
+func varyKeyOf(key string, resp *Response, header http.Header) string {
+	vary := parseVary(resp.Headers)
+	if len(vary) == 0 {
+		return key
+	}
+	return varyKey(key, vary, header)
+}